@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -22,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/TheZeroSlave/zapsentry"
 	"github.com/alexflint/go-arg"
@@ -44,6 +46,8 @@ type Args struct {
 	SentryDSN   string        `arg:"env:SENTRY_DSN"`
 	SentryLevel zapcore.Level `arg:"env:SENTRY_LEVEL" default:"ERROR"`
 
+	SchedulerInterval time.Duration `arg:"env:BROKER_SCHEDULER_INTERVAL"`
+
 	BrokerConfig
 }
 
@@ -162,10 +166,14 @@ func startBrokerServer() {
 
 	b := createBroker(logger)
 
+	// apply the pause and scaling schedules of the instances in the background
+	go b.RunScheduler(context.Background(), args.SchedulerInterval)
+
 	router := mux.NewRouter()
 	brokerapi.AttachRoutes(router, b, NewLagerZapLogger(logger))
 
 	router.Use(b.AuthMiddleware())
+	router.Use(b.RetryAfterMiddleware())
 
 	tlsEnabled := args.CertPath != ""
 
@@ -0,0 +1,124 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func validateAlertConfigs(p *dynamicplans.Plan) error {
+	for i, a := range p.AlertConfigs {
+		if a.EventTypeName == "" {
+			return fmt.Errorf(".alertConfigs[%d].eventTypeName must not be empty", i)
+		}
+
+		if len(a.Notifications) == 0 {
+			return fmt.Errorf(".alertConfigs[%d] must have at least one notification", i)
+		}
+	}
+
+	return nil
+}
+
+// alertConfigKey identifies an alert config across plan versions, as Atlas
+// assigns the IDs. Configs with the same key are matched in order.
+func alertConfigKey(a *mongodbatlas.AlertConfiguration) string {
+	if a.MetricThreshold != nil {
+		return a.EventTypeName + "/" + a.MetricThreshold.MetricName
+	}
+
+	return a.EventTypeName
+}
+
+// reconcileAlertConfigs makes the project's broker-managed alert configs match
+// newConfigs and records the Atlas IDs in them. Configs Atlas creates by
+// default for every project are left alone. If it fails, the configs created
+// so far are deleted again, as their IDs would not be stored and a retry
+// would create duplicates.
+func (b Broker) reconcileAlertConfigs(ctx context.Context, client *mongodbatlas.Client, groupID string, oldConfigs []*mongodbatlas.AlertConfiguration, newConfigs []*mongodbatlas.AlertConfiguration) (err error) {
+	logger := b.funcLogger()
+
+	created := []string{}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		for _, id := range created {
+			_, errDel := client.AlertConfigurations.Delete(ctx, groupID, id)
+			if errDel != nil {
+				logger.Errorw("Failed to delete alert configuration after failed reconciliation", "error", errDel, "id", id)
+			}
+		}
+	}()
+
+	existing := map[string][]*mongodbatlas.AlertConfiguration{}
+	for _, a := range oldConfigs {
+		if a.ID != "" {
+			existing[alertConfigKey(a)] = append(existing[alertConfigKey(a)], a)
+		}
+	}
+
+	for _, a := range newConfigs {
+		key := alertConfigKey(a)
+
+		request := *a
+		request.ID = ""
+
+		if len(existing[key]) == 0 {
+			result, _, err := client.AlertConfigurations.Create(ctx, groupID, &request)
+			if err != nil {
+				return errors.Wrapf(err, "cannot create Alert Configuration %q", key)
+			}
+
+			a.ID = result.ID
+			created = append(created, result.ID)
+			logger.Infow("Created alert configuration", "key", key, "id", a.ID)
+
+			continue
+		}
+
+		old := existing[key][0]
+		existing[key] = existing[key][1:]
+
+		_, _, err := client.AlertConfigurations.Update(ctx, groupID, old.ID, &request)
+		if err != nil {
+			return errors.Wrapf(err, "cannot update Alert Configuration %q", key)
+		}
+
+		a.ID = old.ID
+		logger.Infow("Updated alert configuration", "key", key, "id", a.ID)
+	}
+
+	for key, configs := range existing {
+		for _, a := range configs {
+			r, err := client.AlertConfigurations.Delete(ctx, groupID, a.ID)
+			if err != nil && (r == nil || r.StatusCode != http.StatusNotFound) {
+				return errors.Wrapf(err, "cannot delete Alert Configuration %q", key)
+			}
+
+			logger.Infow("Deleted alert configuration", "key", key, "id", a.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/credentials"
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Binding types, selected by the "type" bind parameter.
+const (
+	bindingTypeDatabaseUser = "databaseUser"
+	bindingTypeAPIKey       = "apiKey"
+)
+
+const defaultAPIKeyRole = "GROUP_READ_ONLY"
+
+// Plan setting for the project roles which API key bindings may request.
+const apiKeyRolesSetting = "apiKeyRoles"
+
+type apiKeyBindingParams struct {
+	Roles      []string                            `json:"roles"`
+	AccessList []*mongodbatlas.WhitelistAPIKeysReq `json:"accessList"`
+}
+
+// bindAPIKey creates a programmatic API key for the project of the instance.
+// The binding ID is used as the key description, so that Unbind can find it.
+func (b Broker) bindAPIKey(ctx context.Context, client *mongodbatlas.Client, bindingID string, p *dynamicplans.Plan, params *apiKeyBindingParams) (spec domain.Binding, err error) {
+	logger := b.funcLogger().With("binding_id", bindingID, "project_id", p.Project.ID)
+
+	if params == nil {
+		params = &apiKeyBindingParams{}
+	}
+
+	if len(params.Roles) == 0 {
+		params.Roles = []string{defaultAPIKeyRole}
+	}
+
+	allowed, err := apiKeyRolesOf(p)
+	if err != nil {
+		return spec, err
+	}
+
+	for _, r := range params.Roles {
+		if !hasString(allowed, r) {
+			return spec, fmt.Errorf("API key role %q is not allowed by the plan, allowed roles are %s", r, strings.Join(allowed, ", "))
+		}
+	}
+
+	for i, e := range params.AccessList {
+		if (e.IPAddress == "") == (e.CidrBlock == "") {
+			return spec, fmt.Errorf("apiKey.accessList[%d] must have either ipAddress or cidrBlock", i)
+		}
+	}
+
+	key, _, err := client.ProjectAPIKeys.Create(ctx, p.Project.ID, &mongodbatlas.APIKeyInput{
+		Desc:  bindingID,
+		Roles: params.Roles,
+	})
+	if err != nil {
+		logger.Errorw("Failed to create Atlas API key", "error", err)
+
+		return spec, errors.Wrap(err, "cannot create API key")
+	}
+
+	if len(params.AccessList) > 0 {
+		_, _, err = client.WhitelistAPIKeys.Create(ctx, p.Project.OrgID, key.ID, params.AccessList)
+		if err != nil {
+			logger.Errorw("Failed to create API key access list", "error", err)
+
+			// don't leave a key behind which the platform doesn't know about
+			_, delErr := client.APIKeys.Delete(ctx, p.Project.OrgID, key.ID)
+			if delErr != nil {
+				logger.Errorw("Failed to delete Atlas API key", "error", delErr, "key_id", key.ID)
+			}
+
+			return spec, errors.Wrap(err, "cannot create API key access list")
+		}
+	}
+
+	logger.Infow("Successfully created Atlas API key", "key_id", key.ID, "roles", params.Roles)
+
+	spec = domain.Binding{
+		Credentials: credentials.APIKey{
+			ID:         key.ID,
+			Desc:       key.Desc,
+			Roles:      key.Roles,
+			PrivateKey: key.PrivateKey,
+			PublicKey:  key.PublicKey,
+			OrgID:      p.Project.OrgID,
+		},
+	}
+
+	return spec, nil
+}
+
+// apiKeyRolesOf returns the roles which API key bindings of the plan may
+// request, only GROUP_READ_ONLY unless the plan settings allow more.
+func apiKeyRolesOf(p *dynamicplans.Plan) ([]string, error) {
+	roles := []string{defaultAPIKeyRole}
+
+	if _, err := decodeSetting(p.Settings, apiKeyRolesSetting, &roles); err != nil {
+		return nil, err
+	}
+
+	for _, r := range roles {
+		if !strings.HasPrefix(r, "GROUP_") {
+			return nil, fmt.Errorf("setting %q: API key role %q is not a project role", apiKeyRolesSetting, r)
+		}
+	}
+
+	return roles, nil
+}
+
+// unbindAPIKey deletes the API key of the binding, and reports whether there was one.
+func (b Broker) unbindAPIKey(ctx context.Context, client *mongodbatlas.Client, bindingID string, p *dynamicplans.Plan) (bool, error) {
+	keys, _, err := client.ProjectAPIKeys.List(ctx, p.Project.ID, &mongodbatlas.ListOptions{ItemsPerPage: 500})
+	if err != nil {
+		return false, errors.Wrap(err, "cannot list project API keys")
+	}
+
+	for _, k := range keys {
+		if k.Desc != bindingID {
+			continue
+		}
+
+		_, err = client.APIKeys.Delete(ctx, p.Project.OrgID, k.ID)
+		if err != nil {
+			return true, errors.Wrapf(err, "cannot delete API key %s", k.ID)
+		}
+
+		b.funcLogger().Infow("Successfully deleted Atlas API key", "binding_id", bindingID, "key_id", k.ID)
+
+		return true, nil
+	}
+
+	return false, nil
+}
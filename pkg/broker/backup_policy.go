@@ -0,0 +1,154 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+var (
+	backupFrequencyTypes = map[string]bool{"hourly": true, "daily": true, "weekly": true, "monthly": true}
+	backupRetentionUnits = map[string]bool{"days": true, "weeks": true, "months": true}
+)
+
+func validateBackupPolicy(p *dynamicplans.Plan) error {
+	bp := p.BackupPolicy
+	if bp == nil {
+		return nil
+	}
+
+	if bp.ClusterName != "" {
+		c := p.ClusterByName(bp.ClusterName)
+		if c == nil {
+			return fmt.Errorf("backup policy refers to unknown cluster %q", bp.ClusterName)
+		}
+
+		if !isTrue(c.ProviderBackupEnabled) {
+			return fmt.Errorf("backup policy requires providerBackupEnabled, which is disabled for %q", c.Name)
+		}
+	}
+
+	if len(backupPolicyClusters(p)) == 0 {
+		return errors.New("backup policy requires at least one cluster with providerBackupEnabled")
+	}
+
+	if len(bp.Policies) > 1 {
+		return errors.New(".backupPolicy.policies must have at most one entry")
+	}
+
+	for _, policy := range bp.Policies {
+		for i, item := range policy.PolicyItems {
+			if !backupFrequencyTypes[item.FrequencyType] {
+				return fmt.Errorf(".backupPolicy.policies[0].policyItems[%d].frequencyType must be one of hourly, daily, weekly, monthly, got %q", i, item.FrequencyType)
+			}
+
+			if !backupRetentionUnits[item.RetentionUnit] {
+				return fmt.Errorf(".backupPolicy.policies[0].policyItems[%d].retentionUnit must be one of days, weeks, months, got %q", i, item.RetentionUnit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backupPolicyClusters returns the clusters the backup policy applies to:
+// the one named in the policy, or all clusters with cloud backups.
+func backupPolicyClusters(p *dynamicplans.Plan) []*mongodbatlas.Cluster {
+	clusters := []*mongodbatlas.Cluster{}
+
+	for _, c := range p.AllClusters() {
+		if p.BackupPolicy.ClusterName != "" && c.Name != p.BackupPolicy.ClusterName {
+			continue
+		}
+
+		if isTrue(c.ProviderBackupEnabled) {
+			clusters = append(clusters, c)
+		}
+	}
+
+	return clusters
+}
+
+// applyBackupPolicy replaces the snapshot schedule and retention of the
+// clusters. Atlas creates one policy per cluster along with the cluster, so
+// the plan's policy items are applied to that one. Clusters whose policy
+// already matches the plan are left alone.
+func (b Broker) applyBackupPolicy(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) error {
+	if p.BackupPolicy == nil {
+		return nil
+	}
+
+	logger := b.funcLogger()
+
+	for _, c := range backupPolicyClusters(p) {
+		current, _, err := client.CloudProviderSnapshotBackupPolicies.Get(ctx, p.Project.ID, c.Name)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get backup policy of %q", c.Name)
+		}
+
+		request := *p.BackupPolicy
+		request.ClusterName = ""
+		request.Policies = nil
+
+		if len(p.BackupPolicy.Policies) > 0 {
+			if len(current.Policies) == 0 {
+				return fmt.Errorf("cluster %q has no backup policy to update", c.Name)
+			}
+
+			request.Policies = []mongodbatlas.Policy{{
+				ID:          current.Policies[0].ID,
+				PolicyItems: p.BackupPolicy.Policies[0].PolicyItems,
+			}}
+		}
+
+		if !backupPolicyChanged(&request, current) {
+			continue
+		}
+
+		_, _, err = client.CloudProviderSnapshotBackupPolicies.Update(ctx, p.Project.ID, c.Name, &request)
+		if err != nil {
+			return errors.Wrapf(err, "cannot update backup policy of %q", c.Name)
+		}
+
+		logger.Infow("Updated backup policy", "cluster", c.Name, "policy", request)
+	}
+
+	return nil
+}
+
+// backupPolicyChanged reports whether any setting of the request differs from
+// the current policy. UpdateSnapshots only affects the update itself and is
+// not compared.
+func backupPolicyChanged(request *mongodbatlas.CloudProviderSnapshotBackupPolicy, current *mongodbatlas.CloudProviderSnapshotBackupPolicy) bool {
+	r := *request
+	r.UpdateSnapshots = nil
+
+	declared, err := toJSONValue(r)
+	if err != nil {
+		return true
+	}
+
+	applied, err := toJSONValue(current)
+	if err != nil {
+		return true
+	}
+
+	return !declaredIn(declared, applied)
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+)
+
+// Plan settings shaping the credentials of database user bindings. The bind
+// parameters "formats" and "connectionOptions" take precedence over them.
+const (
+	bindingFormatsSetting    = "bindingFormats"
+	connectionOptionsSetting = "connectionOptions"
+)
+
+// Extra credential formats which can be added to ConnectionDetails.
+const (
+	formatNonSRV = "nonSrv"
+	formatHosts  = "hosts"
+	formatSpring = "spring"
+	formatNode   = "node"
+	formatPython = "python"
+)
+
+var bindingFormats = []string{formatNonSRV, formatHosts, formatSpring, formatNode, formatPython}
+
+type bindingFormatParams struct {
+	Formats           []string               `json:"formats"`
+	ConnectionOptions map[string]interface{} `json:"connectionOptions"`
+}
+
+// bindingFormatsOf returns the formats and connection options of a binding.
+// The formats of the bind parameters replace those of the plan settings,
+// their connection options are merged into those of the plan settings.
+func bindingFormatsOf(p *dynamicplans.Plan, params bindingFormatParams) (bindingFormatParams, error) {
+	f := bindingFormatParams{}
+
+	if _, err := decodeSetting(p.Settings, bindingFormatsSetting, &f.Formats); err != nil {
+		return f, err
+	}
+
+	if _, err := decodeSetting(p.Settings, connectionOptionsSetting, &f.ConnectionOptions); err != nil {
+		return f, err
+	}
+
+	if len(params.Formats) > 0 {
+		f.Formats = params.Formats
+	}
+
+	if f.ConnectionOptions == nil {
+		f.ConnectionOptions = map[string]interface{}{}
+	}
+
+	for k, v := range params.ConnectionOptions {
+		f.ConnectionOptions[k] = v
+	}
+
+	for _, name := range f.Formats {
+		if !hasString(bindingFormats, name) {
+			return f, fmt.Errorf("unknown binding format %q, must be one of %s", name, strings.Join(bindingFormats, ", "))
+		}
+	}
+
+	return f, nil
+}
+
+// validateBindingFormats checks the binding settings of the plan.
+func validateBindingFormats(p *dynamicplans.Plan) error {
+	f, err := bindingFormatsOf(p, bindingFormatParams{})
+	if err != nil {
+		return err
+	}
+
+	_, err = f.options(connectionOptionsData("", "", "", "", ""))
+
+	return err
+}
+
+// connectionOptionsData returns the values available to the connection option templates.
+func connectionOptionsData(instanceID string, bindingID string, appGUID string, cluster string, database string) map[string]interface{} {
+	return map[string]interface{}{
+		"instance_id": instanceID,
+		"binding_id":  bindingID,
+		"app_guid":    appGUID,
+		"cluster":     cluster,
+		"database":    database,
+	}
+}
+
+// options renders the connection options, whose values are templates.
+func (f bindingFormatParams) options(data map[string]interface{}) (url.Values, error) {
+	values := url.Values{}
+
+	for k, v := range f.ConnectionOptions {
+		t, err := template.New(k).
+			Funcs(sprig.TxtFuncMap()).
+			Option("missingkey=error").
+			Parse(fmt.Sprint(v))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid connection option %q", k)
+		}
+
+		sb := strings.Builder{}
+
+		err = t.Execute(&sb, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot render connection option %q", k)
+		}
+
+		values.Set(k, sb.String())
+	}
+
+	return values, nil
+}
+
+// setOptions adds the options to the query of the connection string,
+// replacing options of the same name.
+func setOptions(u *url.URL, options url.Values) {
+	q := u.Query()
+	for k, v := range options {
+		q[k] = v
+	}
+
+	u.RawQuery = q.Encode()
+}
+
+// addFormats fills the requested formats in. cs is the connection string of
+// the binding and nonSRV its counterpart listing all hosts.
+func (d *ConnectionDetails) addFormats(formats []string, cs *url.URL, nonSRV string, options url.Values, authSource string) error {
+	for _, f := range formats {
+		switch f {
+		case formatNonSRV, formatHosts:
+			if nonSRV == "" {
+				return fmt.Errorf("binding format %q requires a non-SRV connection string, which the cluster doesn't have", f)
+			}
+
+			u, err := url.Parse(nonSRV)
+			if err != nil {
+				return errors.Wrap(err, "cannot parse non-SRV connection string")
+			}
+
+			if f == formatHosts {
+				d.Hosts = strings.Split(u.Host, ",")
+				d.ReplicaSet = u.Query().Get("replicaSet")
+				d.AuthSource = authSource
+
+				continue
+			}
+
+			u.Path = cs.Path
+			u.User = cs.User
+			setOptions(u, options)
+			d.NonSRVConnectionString = u.String()
+
+		case formatSpring:
+			d.SpringDataMongoDBURI = d.ConnectionString
+			d.SpringDataMongoDBDatabase = d.Database
+
+		case formatNode:
+			d.URL = d.ConnectionString
+			d.DBName = d.Database
+
+		case formatPython:
+			host := *cs
+			host.User = nil
+			d.Host = host.String()
+			d.AuthSource = authSource
+		}
+	}
+
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
@@ -34,17 +35,34 @@ const (
 	overrideBindDBRole = "overrideBindDBRole"
 )
 
-// ConnectionDetails will be returned when a new binding is created.
+// ConnectionDetails will be returned when a new binding is created. The
+// optional fields are added by the binding formats.
 type ConnectionDetails struct {
 	Username         string `json:"username"`
-	Password         string `json:"password"`
+	Password         string `json:"password,omitempty"`
 	URI              string `json:"uri"`
 	ConnectionString string `json:"connectionString"`
 	Database         string `json:"database"`
+
+	NonSRVConnectionString string   `json:"nonSrvConnectionString,omitempty"`
+	Hosts                  []string `json:"hosts,omitempty"`
+	ReplicaSet             string   `json:"replicaSet,omitempty"`
+	AuthSource             string   `json:"authSource,omitempty"`
+
+	SpringDataMongoDBURI      string `json:"spring.data.mongodb.uri,omitempty"`
+	SpringDataMongoDBDatabase string `json:"spring.data.mongodb.database,omitempty"`
+	URL                       string `json:"url,omitempty"`
+	DBName                    string `json:"dbName,omitempty"`
+	Host                      string `json:"host,omitempty"`
+
+	Certificate          string `json:"certificate,omitempty"`
+	PrivateKey           string `json:"privateKey,omitempty"`
+	CertificateExpiresAt string `json:"certificateExpiresAt,omitempty"`
 }
 
 // Bind will create a new database user with a username matching the binding ID
-// and a randomly generated password. The user credentials will be returned back.
+// and a randomly generated password, or an X.509 certificate. The user
+// credentials will be returned back.
 func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, details domain.BindDetails, asyncAllowed bool) (spec domain.Binding, err error) {
 	logger := b.funcLogger().With("instance_id", instanceID, "binding_id", bindingID)
 	logger.Infow("Creating binding", "details", details)
@@ -66,14 +84,64 @@ func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, d
 		return spec, fmt.Errorf("plan ID %q not found in catalog", details.PlanID)
 	}
 
+	// Pick the cluster to connect to, the first one is the default.
+	params := struct {
+		bindingFormatParams
+		Type           string               `json:"type"`
+		Cluster        string               `json:"cluster"`
+		ConnectionType string               `json:"connectionType"`
+		APIKey         *apiKeyBindingParams `json:"apiKey"`
+		X509           *x509BindingParams   `json:"x509"`
+	}{}
+
+	if len(details.RawParameters) > 0 {
+		err = json.Unmarshal(details.RawParameters, &params)
+		if err != nil {
+			return spec, errors.Wrap(err, "cannot unmarshal raw parameters")
+		}
+	}
+
+	switch params.Type {
+	case "", bindingTypeDatabaseUser, bindingTypeX509:
+	case bindingTypeAPIKey:
+		return b.bindAPIKey(ctx, client, bindingID, p, params.APIKey)
+	default:
+		return spec, fmt.Errorf("unknown binding type %q", params.Type)
+	}
+
+	target := p.ClusterByName(params.Cluster)
+	if target == nil {
+		return spec, fmt.Errorf("cluster %q not found in plan", params.Cluster)
+	}
+
 	// Fetch the cluster from Atlas to ensure it exists.
-	cluster, _, err := client.Clusters.Get(ctx, p.Project.ID, p.Cluster.Name)
+	cluster, _, err := clusterService{client}.Get(ctx, p.Project.ID, target)
 	if err != nil {
 		logger.Errorw("Failed to get existing cluster", "error", err)
 
 		return
 	}
 
+	rawURI, err := connectionString(cluster.ConnectionStrings, params.ConnectionType)
+	if err != nil {
+		return
+	}
+
+	formats, err := bindingFormatsOf(p, params.bindingFormatParams)
+	if err != nil {
+		return
+	}
+
+	isX509 := params.Type == bindingTypeX509
+
+	months := 0
+	if isX509 {
+		months, err = x509MonthsOf(p, params.X509)
+		if err != nil {
+			return
+		}
+	}
+
 	// Generate a cryptographically secure random password.
 	password, err := generatePassword()
 	if err != nil {
@@ -83,26 +151,22 @@ func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, d
 		return
 	}
 
-	user, err := b.userFromParams(bindingID, password, details.RawParameters, p)
+	user, err := b.userFromParams(bindingID, password, details.RawParameters, p, target)
 	if err != nil {
 		logger.Errorw("Couldn't create user from the passed parameters", "error", err, "details", details)
 
 		return
 	}
 
-	// Create a new Atlas database user from the generated definition.
-	_, _, err = client.DatabaseUsers.Create(ctx, p.Project.ID, user)
-	if err != nil {
-		logger.Errorw("Failed to create Atlas database user", "error", err)
-
-		return
+	if isX509 {
+		toX509User(user)
 	}
 
-	logger.Infow("Successfully created Atlas database user")
-
-	cs, err := url.Parse(cluster.ConnectionStrings.StandardSrv)
+	// The credentials are put together before the user is created, so that
+	// invalid formats or options don't leave a user behind.
+	cs, err := url.Parse(rawURI)
 	if err != nil {
-		logger.Errorw("Failed to parse connection string", "error", err, "connString", cluster.ConnectionStrings.StandardSrv)
+		logger.Errorw("Failed to parse connection string", "error", err, "connString", rawURI)
 
 		return
 	}
@@ -111,7 +175,7 @@ func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, d
 
 	connDetails := ConnectionDetails{
 		Username: bindingID,
-		Password: password,
+		Password: user.Password,
 	}
 
 	if len(user.Roles) > 0 {
@@ -119,13 +183,55 @@ func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, d
 		logger.Infow("Detected roles, override the name of the db to connect", "connectionString", cs)
 	}
 
+	appGUID := details.AppGUID
+	if details.BindResource != nil && details.BindResource.AppGuid != "" {
+		appGUID = details.BindResource.AppGuid
+	}
+
+	options, err := formats.options(connectionOptionsData(instanceID, bindingID, appGUID, cluster.Name, cs.Path))
+	if err != nil {
+		return
+	}
+
+	if isX509 {
+		setOptions(cs, x509Options())
+	}
+
+	setOptions(cs, options)
+
 	logger.Infow("New User ConnectionString", "connectionString", cs)
 
-	cs.User = url.UserPassword(user.Username, user.Password)
+	if !isX509 {
+		cs.User = url.UserPassword(user.Username, user.Password)
+	}
 	connDetails.ConnectionString = cs.String()
 	connDetails.Database = cs.Path
 	connDetails.URI = cs.String()
 
+	nonSRV := nonSRVConnectionString(cluster.ConnectionStrings, params.ConnectionType)
+
+	err = connDetails.addFormats(formats.Formats, cs, nonSRV, cs.Query(), user.DatabaseName)
+	if err != nil {
+		return
+	}
+
+	// Create a new Atlas database user from the generated definition.
+	_, _, err = client.DatabaseUsers.Create(ctx, p.Project.ID, user)
+	if err != nil {
+		logger.Errorw("Failed to create Atlas database user", "error", err)
+
+		return
+	}
+
+	logger.Infow("Successfully created Atlas database user")
+
+	if isX509 {
+		err = b.addCertificate(ctx, client, p, user, months, &connDetails)
+		if err != nil {
+			return
+		}
+	}
+
 	spec = domain.Binding{
 		Credentials: connDetails,
 	}
@@ -133,8 +239,9 @@ func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, d
 	return
 }
 
-// Unbind will delete the database user for a specific binding. The database
-// user should have the binding ID as its username.
+// Unbind will delete the database user or API key for a specific binding. The
+// database user should have the binding ID as its username, the API key as its
+// description.
 func (b Broker) Unbind(ctx context.Context, instanceID string, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (spec domain.UnbindSpec, err error) {
 	logger := b.funcLogger().With("instance_id", instanceID, "binding_id", bindingID)
 	logger.Infow("Releasing binding", "details", details)
@@ -144,22 +251,65 @@ func (b Broker) Unbind(ctx context.Context, instanceID string, bindingID string,
 		return
 	}
 
+	// Unbind doesn't get the bind parameters, so look for an API key first.
+	// Database users are still unbound if the API keys cannot be listed.
+	found, errKey := b.unbindAPIKey(ctx, client, bindingID, p)
+	if found {
+		if errKey != nil {
+			logger.Errorw("Failed to delete Atlas API key", "error", errKey)
+
+			return domain.UnbindSpec{}, errKey
+		}
+
+		return domain.UnbindSpec{}, nil
+	}
+
+	if errKey != nil {
+		logger.Warnw("Cannot look up Atlas API keys, unbinding a database user", "error", errKey)
+	}
+
 	// Fetch the cluster from Atlas to ensure it exists.
-	_, _, err = client.Clusters.Get(ctx, p.Project.ID, p.Cluster.Name)
+	_, _, err = clusterService{client}.Get(ctx, p.Project.ID, p.Cluster)
 	if err != nil {
 		logger.Errorw("Failed to get existing cluster", "error", err)
 
 		return
 	}
 
-	// Delete database user which has the binding ID as its username.
-	_, err = client.DatabaseUsers.Delete(ctx, "admin", p.Project.ID, bindingID)
-	if err != nil {
+	// Delete database user which has the binding ID as its username. Users of
+	// X.509 bindings live in $external, deleting them revokes their certificates.
+	r, err := client.DatabaseUsers.Delete(ctx, "admin", p.Project.ID, bindingID)
+	if r != nil && r.StatusCode == http.StatusNotFound {
+		r, err = client.DatabaseUsers.Delete(ctx, x509AuthSource, p.Project.ID, bindingID)
+	}
+
+	notFound := r != nil && r.StatusCode == http.StatusNotFound
+	if err != nil && !notFound {
 		logger.Errorw("Failed to delete Atlas database user", "error", err)
 
 		return
 	}
 
+	// the successors RotateCredentials created for the binding's user go with it
+	rotated, err := deleteRotatedUsers(ctx, client, p.Project.ID, bindingID)
+	if err != nil {
+		logger.Errorw("Failed to delete rotated Atlas database users", "error", err)
+
+		return
+	}
+
+	if notFound && rotated == 0 {
+		// the binding may have been an API key after all
+		if errKey != nil {
+			return domain.UnbindSpec{}, errKey
+		}
+
+		// don't fail if the user is already deleted
+		logger.Infow("Atlas database user already deleted")
+
+		return domain.UnbindSpec{}, nil
+	}
+
 	logger.Infow("Successfully deleted Atlas database user")
 
 	spec = domain.UnbindSpec{}
@@ -198,7 +348,7 @@ func generatePassword() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func (b *Broker) userFromParams(bindingID string, password string, rawParams []byte, plan *dynamicplans.Plan) (*mongodbatlas.DatabaseUser, error) {
+func (b *Broker) userFromParams(bindingID string, password string, rawParams []byte, plan *dynamicplans.Plan, cluster *mongodbatlas.Cluster) (*mongodbatlas.DatabaseUser, error) {
 	logger := b.funcLogger().With("binding_id", bindingID)
 	// Set up a params object which will be used for deserialization.
 	params := struct {
@@ -244,11 +394,18 @@ func (b *Broker) userFromParams(bindingID string, password string, rawParams []b
 
 	if len(params.User.Scopes) == 0 {
 		params.User.Scopes = append(params.User.Scopes, mongodbatlas.Scope{
-			Name: plan.Cluster.Name,
+			Name: cluster.Name,
 			Type: "CLUSTER",
 		})
 	}
 
+	// custom roles are defined on the project level and always live in "admin"
+	for i, r := range params.User.Roles {
+		if r.DatabaseName == "" && customDBRoleByName(plan.CustomDBRoles, r.RoleName) != nil {
+			params.User.Roles[i].DatabaseName = "admin"
+		}
+	}
+
 	logger.Debugw("userFromParams", "params", params)
 
 	// If no role is specified we default to read/write on any database.
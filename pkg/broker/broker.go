@@ -48,6 +48,7 @@ type Broker struct {
 	cfg         Config
 	catalog     *catalog
 	userAgent   string
+	pollHints   *pollHints
 }
 
 type Config struct {
@@ -79,6 +80,7 @@ func New(
 		credentials: credentials,
 		cfg:         cfg,
 		userAgent:   userAgent,
+		pollHints:   &pollHints{},
 	}
 
 	b.buildCatalog()
@@ -135,6 +137,8 @@ func (b *Broker) parsePlan(ctx dynamicplans.Context, planID string) (dp *dynamic
 		logger.Infow("Merged final plan instance:", "plan", dp.SafeCopy())
 	}
 
+	dp.NormalizeClusters()
+
 	return dp, nil
 }
 
@@ -188,6 +192,14 @@ func (b *Broker) getClient(ctx context.Context, instanceID string, planID string
 		return
 	}
 
+	client, err = b.planClient(ctx, dp)
+
+	return
+}
+
+// planClient creates an Atlas client with the API key of the plan's
+// organization, and merges the existing Atlas project into the plan.
+func (b *Broker) planClient(ctx context.Context, dp *dynamicplans.Plan) (client *mongodbatlas.Client, err error) {
 	key := credentials.APIKey{}
 
 	switch {
@@ -235,6 +247,33 @@ func (b *Broker) getClient(ctx context.Context, instanceID string, planID string
 	return
 }
 
+// savePlan replaces the plan stored for an existing instance.
+func (b *Broker) savePlan(ctx context.Context, instanceID string, p *dynamicplans.Plan) error {
+	state, err := b.getState(ctx, p.Project.OrgID)
+	if err != nil {
+		return err
+	}
+
+	s, err := state.FindOne(ctx, instanceID)
+	if err != nil {
+		return errors.Wrap(err, "cannot find instance in state storage")
+	}
+
+	s.Parameters, err = encodePlan(*p)
+	if err != nil {
+		return err
+	}
+
+	err = state.DeleteOne(ctx, instanceID)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete instance from state storage")
+	}
+
+	_, err = state.Put(ctx, instanceID, s)
+
+	return errors.Wrap(err, "cannot put instance into state storage")
+}
+
 func (b *Broker) getState(ctx context.Context, orgID string) (*statestorage.RealmStateStorage, error) {
 	key, err := b.credentials.ByOrg(orgID)
 	if err != nil {
@@ -252,6 +291,11 @@ func (b *Broker) AuthMiddleware() mux.MiddlewareFunc {
 	return nil
 }
 
+// RetryAfterMiddleware sets the Retry-After header on last_operation responses.
+func (b *Broker) RetryAfterMiddleware() mux.MiddlewareFunc {
+	return retryAfterMiddleware(b.pollHints)
+}
+
 func (b *Broker) GetDashboardURL(groupID, clusterName string) string {
 	apiURL, err := url.Parse(b.cfg.AtlasURL)
 	if err != nil {
@@ -262,6 +306,16 @@ func (b *Broker) GetDashboardURL(groupID, clusterName string) string {
 	return apiURL.String() + fmt.Sprintf("#clusters/detail/%s", clusterName)
 }
 
+func (b *Broker) GetActivityFeedURL(groupID string) string {
+	apiURL, err := url.Parse(b.cfg.AtlasURL)
+	if err != nil {
+		return err.Error()
+	}
+	apiURL.Path = fmt.Sprintf("/v2/%s", groupID)
+
+	return apiURL.String() + "#activity"
+}
+
 func encodePlan(v dynamicplans.Plan) (string, error) {
 	b := new(bytes.Buffer)
 	b64 := base64.NewEncoder(base64.StdEncoding, b)
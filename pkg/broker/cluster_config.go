@@ -0,0 +1,191 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// applyClusterConfig applies the parts of a plan which Atlas only accepts once
+// the clusters are IDLE. It is called by LastOperation after every provision
+// and update, so each step must be idempotent. It reports whether any
+// clusters are restarting to apply changed processArgs, in which case the
+// remaining steps are left for the next call.
+func (b Broker) applyClusterConfig(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) (bool, error) {
+	restarting, err := b.applyProcessArgs(ctx, client, p)
+	if err != nil || restarting {
+		return restarting, err
+	}
+
+	if err := b.applyGlobalClusters(ctx, client, p); err != nil {
+		return false, err
+	}
+
+	if err := b.applyBackupPolicy(ctx, client, p); err != nil {
+		return false, err
+	}
+
+	return false, b.applySearchIndexes(ctx, client, p)
+}
+
+// configProgress reports on the resources which Atlas keeps setting up after
+// the clusters are IDLE, and whether the operation has to wait for them.
+func (b Broker) configProgress(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) (string, bool, error) {
+	network, networkPending, err := b.networkProgress(ctx, client, p)
+	if err != nil {
+		return "", false, err
+	}
+
+	search, searchPending, err := b.searchIndexProgress(ctx, client, p)
+	if err != nil {
+		return "", false, err
+	}
+
+	statuses := []string{}
+	for _, s := range []string{network, search} {
+		if s != "" {
+			statuses = append(statuses, s)
+		}
+	}
+
+	return strings.Join(statuses, ", "), networkPending || searchPending, nil
+}
+
+func validateGlobalClusters(p *dynamicplans.Plan) error {
+	for _, g := range p.GlobalClusters {
+		c := p.ClusterByName(g.ClusterName)
+		if c == nil {
+			return fmt.Errorf("global cluster config refers to unknown cluster %q", g.ClusterName)
+		}
+
+		if c.ClusterType != "GEOSHARDED" {
+			return fmt.Errorf("global cluster config requires cluster %q to have clusterType GEOSHARDED", c.Name)
+		}
+	}
+
+	return nil
+}
+
+// applyGlobalClusters adds the declared custom zone mappings and any missing
+// managed namespaces. Namespaces are never removed, as that would
+// silently change how existing data is distributed.
+func (b Broker) applyGlobalClusters(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) error {
+	logger := b.funcLogger()
+
+	for _, g := range p.GlobalClusters {
+		c := p.ClusterByName(g.ClusterName)
+		if c == nil {
+			return fmt.Errorf("global cluster config refers to unknown cluster %q", g.ClusterName)
+		}
+
+		existing, _, err := client.GlobalClusters.Get(ctx, p.Project.ID, c.Name)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get global cluster config for %q", c.Name)
+		}
+
+		for i := range g.ManagedNamespaces {
+			ns := g.ManagedNamespaces[i]
+			if hasManagedNamespace(existing.ManagedNamespaces, ns) {
+				continue
+			}
+
+			_, _, err = client.GlobalClusters.AddManagedNamespace(ctx, p.Project.ID, c.Name, &ns)
+			if err != nil {
+				return errors.Wrapf(err, "cannot add managed namespace %s.%s to %q", ns.Db, ns.Collection, c.Name)
+			}
+
+			logger.Infow("Added managed namespace", "cluster", c.Name, "namespace", ns)
+		}
+
+		if len(g.CustomZoneMappings) > 0 {
+			request := &mongodbatlas.CustomZoneMappingsRequest{
+				CustomZoneMappings: g.CustomZoneMappings,
+			}
+
+			_, _, err = client.GlobalClusters.AddCustomZoneMappings(ctx, p.Project.ID, c.Name, request)
+			if err != nil {
+				return errors.Wrapf(err, "cannot add custom zone mappings to %q", c.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasManagedNamespace(namespaces []mongodbatlas.ManagedNamespace, ns mongodbatlas.ManagedNamespace) bool {
+	for _, n := range namespaces {
+		if n.Db == ns.Db && n.Collection == ns.Collection {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toJSONValue converts v to its generic JSON representation.
+func toJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+
+	return out, err
+}
+
+// declaredIn reports whether every value in declared is also in current.
+// Objects in current may have additional keys.
+func declaredIn(declared interface{}, current interface{}) bool {
+	switch d := declared.(type) {
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for k, v := range d {
+			if !declaredIn(v, c[k]) {
+				return false
+			}
+		}
+
+		return true
+
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok || len(c) != len(d) {
+			return false
+		}
+
+		for i := range d {
+			if !declaredIn(d[i], c[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return declared == current
+}
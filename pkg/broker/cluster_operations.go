@@ -0,0 +1,280 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func init() {
+	cluster := map[string]interface{}{
+		"type":        "string",
+		"description": "Defaults to the main cluster of the instance.",
+	}
+
+	RegisterOperation(Operation{
+		Name:        "TestFailover",
+		Description: "Restarts the primaries of a cluster, making the secondaries hold an election.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"cluster": cluster,
+			},
+		},
+		Async:    true,
+		Handler:  testFailover,
+		Progress: testFailoverProgress,
+	})
+
+	RegisterOperation(Operation{
+		Name:        "TakeSnapshot",
+		Description: "Takes an on-demand cloud backup snapshot of a cluster.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"cluster":         cluster,
+				"description":     map[string]interface{}{"type": "string"},
+				"retentionInDays": map[string]interface{}{"type": "integer", "minimum": 1, "default": 1},
+			},
+		},
+		Async:    true,
+		Handler:  takeSnapshot,
+		Progress: takeSnapshotProgress,
+	})
+}
+
+// OperationRequest.Data keys of TestFailover and TakeSnapshot.
+const (
+	clusterOpCluster   = "cluster"
+	clusterOpPrimaries = "primaries"
+	clusterOpSnapshot  = "snapshotId"
+)
+
+type clusterOperationParams struct {
+	Cluster         string `json:"cluster"`
+	Description     string `json:"description"`
+	RetentionInDays int    `json:"retentionInDays"`
+}
+
+// operationCluster returns the dedicated cluster a cluster operation applies to.
+func (r OperationRequest) operationCluster(name string) (*mongodbatlas.Cluster, error) {
+	c := r.Plan.ClusterByName(name)
+	if c == nil {
+		return nil, fmt.Errorf("cluster %q not found in plan", name)
+	}
+
+	if isServerless(c) || isSharedTier(c) {
+		return nil, fmt.Errorf("cluster %q is not a dedicated cluster", c.Name)
+	}
+
+	return c, nil
+}
+
+func testFailover(ctx context.Context, req OperationRequest) error {
+	params := clusterOperationParams{}
+
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
+
+	c, err := req.operationCluster(params.Cluster)
+	if err != nil {
+		return err
+	}
+
+	groupID := req.Plan.Project.ID
+
+	cluster, _, err := req.Client.Clusters.Get(ctx, groupID, c.Name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get cluster %q", c.Name)
+	}
+
+	if cluster.StateName != "IDLE" {
+		return fmt.Errorf("cluster %q is %s, failover requires it to be IDLE", c.Name, cluster.StateName)
+	}
+
+	primaries, err := clusterPrimaries(ctx, req.Client, groupID, cluster)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("groups/%s/clusters/%s/restartPrimaries", groupID, url.PathEscape(c.Name))
+
+	r, err := req.Client.NewRequest(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot create request")
+	}
+
+	_, err = req.Client.Do(ctx, r, nil)
+	if err != nil {
+		return errors.Wrapf(err, "cannot start failover of %q", c.Name)
+	}
+
+	req.Data[clusterOpCluster] = c.Name
+	req.Data[clusterOpPrimaries] = strings.Join(primaries, ",")
+
+	return nil
+}
+
+// testFailoverProgress waits for new primaries to be elected. Sharded
+// clusters only expose their mongos hosts, so for them the failover is
+// considered done once the cluster is IDLE again.
+func testFailoverProgress(ctx context.Context, req OperationRequest) (domain.LastOperation, error) {
+	name := req.Data[clusterOpCluster]
+
+	cluster, _, err := req.Client.Clusters.Get(ctx, req.Plan.Project.ID, name)
+	if err != nil {
+		return domain.LastOperation{}, errors.Wrapf(err, "cannot get cluster %q", name)
+	}
+
+	primaries, err := clusterPrimaries(ctx, req.Client, req.Plan.Project.ID, cluster)
+	if err != nil {
+		return domain.LastOperation{}, err
+	}
+
+	before := req.Data[clusterOpPrimaries]
+	after := strings.Join(primaries, ",")
+
+	switch {
+	case cluster.StateName != "IDLE":
+		return domain.LastOperation{
+			State:       domain.InProgress,
+			Description: fmt.Sprintf("failover of %q: cluster is %s", name, cluster.StateName),
+		}, nil
+
+	case before != "" && (after == "" || after == before):
+		return domain.LastOperation{
+			State:       domain.InProgress,
+			Description: fmt.Sprintf("failover of %q: waiting for a new primary, current: %s", name, before),
+		}, nil
+	}
+
+	return domain.LastOperation{
+		State:       domain.Succeeded,
+		Description: fmt.Sprintf("failover of %q completed, primary: %s", name, after),
+	}, nil
+}
+
+// clusterPrimaries returns the sorted hosts of the cluster currently acting
+// as replica set primaries.
+func clusterPrimaries(ctx context.Context, client *mongodbatlas.Client, groupID string, cluster *mongodbatlas.Cluster) ([]string, error) {
+	hosts := map[string]bool{}
+	for _, h := range strings.Split(strings.TrimPrefix(cluster.MongoURI, "mongodb://"), ",") {
+		hosts[h] = true
+	}
+
+	processes, _, err := client.Processes.List(ctx, groupID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list processes")
+	}
+
+	primaries := []string{}
+	for _, p := range processes {
+		host := p.Hostname + ":" + strconv.Itoa(p.Port)
+		if hosts[host] && p.TypeName == "REPLICA_PRIMARY" {
+			primaries = append(primaries, host)
+		}
+	}
+
+	sort.Strings(primaries)
+
+	return primaries, nil
+}
+
+func takeSnapshot(ctx context.Context, req OperationRequest) error {
+	params := clusterOperationParams{
+		RetentionInDays: 1,
+	}
+
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
+
+	c, err := req.operationCluster(params.Cluster)
+	if err != nil {
+		return err
+	}
+
+	if params.RetentionInDays < 1 {
+		return errors.New("retentionInDays must be at least 1")
+	}
+
+	if params.Description == "" {
+		params.Description = fmt.Sprintf("On-demand snapshot of instance %s", req.InstanceID)
+	}
+
+	path := &mongodbatlas.SnapshotReqPathParameters{
+		GroupID:     req.Plan.Project.ID,
+		ClusterName: c.Name,
+	}
+
+	snapshot, _, err := req.Client.CloudProviderSnapshots.Create(ctx, path, &mongodbatlas.CloudProviderSnapshot{
+		Description:     params.Description,
+		RetentionInDays: params.RetentionInDays,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "cannot take snapshot of %q", c.Name)
+	}
+
+	req.Data[clusterOpCluster] = c.Name
+	req.Data[clusterOpSnapshot] = snapshot.ID
+
+	return nil
+}
+
+func takeSnapshotProgress(ctx context.Context, req OperationRequest) (domain.LastOperation, error) {
+	path := &mongodbatlas.SnapshotReqPathParameters{
+		GroupID:     req.Plan.Project.ID,
+		ClusterName: req.Data[clusterOpCluster],
+		SnapshotID:  req.Data[clusterOpSnapshot],
+	}
+
+	snapshot, _, err := req.Client.CloudProviderSnapshots.GetOneCloudProviderSnapshot(ctx, path)
+	if err != nil {
+		return domain.LastOperation{}, errors.Wrapf(err, "cannot get snapshot %s", path.SnapshotID)
+	}
+
+	switch snapshot.Status {
+	case "completed":
+		return domain.LastOperation{
+			State:       domain.Succeeded,
+			Description: fmt.Sprintf("snapshot %s of %q completed, expires at %s", snapshot.ID, path.ClusterName, snapshot.ExpiresAt),
+		}, nil
+
+	case "failed":
+		return domain.LastOperation{
+			State: domain.Failed,
+			Description: fmt.Sprintf(
+				"snapshot %s of %q failed, check the Atlas activity feed for details: %s",
+				snapshot.ID, path.ClusterName, req.broker.GetActivityFeedURL(path.GroupID),
+			),
+		}, nil
+	}
+
+	return domain.LastOperation{
+		State:       domain.InProgress,
+		Description: fmt.Sprintf("snapshot %s of %q is %s", snapshot.ID, path.ClusterName, snapshot.Status),
+	}, nil
+}
@@ -0,0 +1,360 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Special provider names selecting shared-tier clusters and serverless instances.
+const (
+	providerTenant     = "TENANT"
+	providerServerless = "SERVERLESS"
+)
+
+// clusterNotFound is reported for clusters which Atlas responds to with 404.
+const clusterNotFound = "NOT_FOUND"
+
+var knownClusterStates = map[string]bool{
+	"IDLE":          true,
+	"CREATING":      true,
+	"UPDATING":      true,
+	"REPAIRING":     true,
+	"DELETING":      true,
+	"DELETED":       true,
+	clusterNotFound: true,
+}
+
+var sharedTierSizes = map[string]bool{
+	"M0": true,
+	"M2": true,
+	"M5": true,
+}
+
+func isServerless(c *mongodbatlas.Cluster) bool {
+	return c.ProviderSettings != nil && c.ProviderSettings.ProviderName == providerServerless
+}
+
+func isSharedTier(c *mongodbatlas.Cluster) bool {
+	return c.ProviderSettings != nil && c.ProviderSettings.ProviderName == providerTenant
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// pauseClusters pauses or resumes all clusters of the plan.
+func pauseClusters(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan, paused bool) error {
+	for _, c := range p.AllClusters() {
+		if isServerless(c) || isSharedTier(c) {
+			return errors.New("only dedicated clusters can be paused")
+		}
+	}
+
+	request := &mongodbatlas.Cluster{
+		Paused: &paused,
+	}
+
+	for _, c := range p.AllClusters() {
+		_, _, err := client.Clusters.Update(ctx, p.Project.ID, c.Name, request)
+		if err != nil {
+			return errors.Wrap(err, "cannot update Cluster")
+		}
+	}
+
+	return nil
+}
+
+// validateClusters checks all cluster definitions of a plan template.
+func validateClusters(p *dynamicplans.Plan) error {
+	if p.Cluster == nil {
+		return errors.New("either .cluster or .clusters must be specified")
+	}
+
+	names := map[string]bool{}
+	for i, c := range p.AllClusters() {
+		if err := validateCluster(c); err != nil {
+			return errors.Wrapf(err, "cluster #%d", i)
+		}
+
+		if names[c.Name] {
+			return fmt.Errorf("duplicate cluster name %q", c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	return nil
+}
+
+// validateCluster checks the cluster definition of a plan template against
+// the restrictions of the selected cluster type.
+func validateCluster(c *mongodbatlas.Cluster) error {
+	if c == nil || c.ProviderSettings == nil {
+		return errors.New(".cluster.providerSettings must not be empty")
+	}
+
+	ps := c.ProviderSettings
+	if ps.ProviderName == "" {
+		return errors.New(".cluster.providerSettings.providerName must not be empty")
+	}
+
+	switch ps.ProviderName {
+	case providerServerless:
+		if ps.BackingProviderName == "" {
+			return errors.New(".cluster.providerSettings.backingProviderName must not be empty for serverless instances")
+		}
+
+		if ps.RegionName == "" {
+			return errors.New(".cluster.providerSettings.regionName must not be empty for serverless instances")
+		}
+
+		if ps.InstanceSizeName != "" || c.AutoScaling != nil || c.DiskSizeGB != nil ||
+			len(c.ReplicationSpecs) > 0 || isTrue(c.ProviderBackupEnabled) || isTrue(c.PitEnabled) {
+			return errors.New("serverless instances only support .cluster.name and .cluster.providerSettings.{backingProviderName,regionName}")
+		}
+
+	case providerTenant:
+		if ps.BackingProviderName == "" {
+			return errors.New(".cluster.providerSettings.backingProviderName must not be empty for shared-tier clusters")
+		}
+
+		if !sharedTierSizes[ps.InstanceSizeName] {
+			return fmt.Errorf(".cluster.providerSettings.instanceSizeName must be one of M0, M2, M5 for shared-tier clusters, got %q", ps.InstanceSizeName)
+		}
+
+		if c.AutoScaling != nil || ps.AutoScaling != nil || c.DiskSizeGB != nil || c.BiConnector != nil ||
+			len(c.ReplicationSpecs) > 0 || isTrue(c.ProviderBackupEnabled) || isTrue(c.PitEnabled) {
+			return errors.New("shared-tier clusters don't support autoScaling, diskSizeGB, biConnector, replicationSpecs, providerBackupEnabled or pitEnabled")
+		}
+
+	default:
+		if ps.InstanceSizeName == "" {
+			return errors.New(".cluster.providerSettings.instanceSizeName must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// clusterService hides the differences between clusters and serverless
+// instances, which use a separate Atlas API not covered by the Go client yet.
+type clusterService struct {
+	client *mongodbatlas.Client
+}
+
+// serverlessInstance is the subset of the Atlas serverless instance resource used by the broker.
+type serverlessInstance struct {
+	ID                string                          `json:"id,omitempty"`
+	Name              string                          `json:"name,omitempty"`
+	GroupID           string                          `json:"groupId,omitempty"`
+	MongoDBVersion    string                          `json:"mongoDBVersion,omitempty"`
+	StateName         string                          `json:"stateName,omitempty"`
+	ProviderSettings  *mongodbatlas.ProviderSettings  `json:"providerSettings,omitempty"`
+	ConnectionStrings *mongodbatlas.ConnectionStrings `json:"connectionStrings,omitempty"`
+}
+
+func (s serverlessInstance) cluster() *mongodbatlas.Cluster {
+	return &mongodbatlas.Cluster{
+		ID:                s.ID,
+		Name:              s.Name,
+		GroupID:           s.GroupID,
+		MongoDBVersion:    s.MongoDBVersion,
+		StateName:         s.StateName,
+		ProviderSettings:  s.ProviderSettings,
+		ConnectionStrings: s.ConnectionStrings,
+	}
+}
+
+func (s clusterService) serverlessPath(groupID string, name string) string {
+	path := fmt.Sprintf("groups/%s/serverless", groupID)
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+
+	return path
+}
+
+func (s clusterService) Create(ctx context.Context, groupID string, c *mongodbatlas.Cluster) (*mongodbatlas.Cluster, *mongodbatlas.Response, error) {
+	if !isServerless(c) {
+		return s.client.Clusters.Create(ctx, groupID, c)
+	}
+
+	request := serverlessInstance{
+		Name: c.Name,
+		ProviderSettings: &mongodbatlas.ProviderSettings{
+			ProviderName:        providerServerless,
+			BackingProviderName: c.ProviderSettings.BackingProviderName,
+			RegionName:          c.ProviderSettings.RegionName,
+		},
+	}
+
+	req, err := s.client.NewRequest(ctx, http.MethodPost, s.serverlessPath(groupID, ""), request)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot create request")
+	}
+
+	result := serverlessInstance{}
+	resp, err := s.client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return result.cluster(), resp, nil
+}
+
+func (s clusterService) Get(ctx context.Context, groupID string, c *mongodbatlas.Cluster) (*mongodbatlas.Cluster, *mongodbatlas.Response, error) {
+	if !isServerless(c) {
+		return s.client.Clusters.Get(ctx, groupID, c.Name)
+	}
+
+	req, err := s.client.NewRequest(ctx, http.MethodGet, s.serverlessPath(groupID, c.Name), nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot create request")
+	}
+
+	result := serverlessInstance{}
+	resp, err := s.client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return result.cluster(), resp, nil
+}
+
+// clusterState is the Atlas state of one of the instance's clusters.
+type clusterState struct {
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Paused bool   `json:"paused,omitempty"`
+}
+
+type clusterStates []clusterState
+
+// all reports whether every cluster is in one of the given states.
+func (s clusterStates) all(states ...string) bool {
+	for _, c := range s {
+		found := false
+		for _, state := range states {
+			found = found || c.State == state
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// find returns the name of the first cluster in the given state.
+func (s clusterStates) find(state string) (string, bool) {
+	for _, c := range s {
+		if c.State == state {
+			return c.Name, true
+		}
+	}
+
+	return "", false
+}
+
+func (s clusterStates) has(state string) bool {
+	_, ok := s.find(state)
+
+	return ok
+}
+
+func (s clusterStates) paused() bool {
+	for _, c := range s {
+		if c.Paused {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s clusterStates) allPaused() bool {
+	for _, c := range s {
+		if !c.Paused {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns just the state for a single cluster, to keep descriptions
+// unchanged for single-cluster plans.
+func (s clusterStates) String() string {
+	if len(s) == 1 {
+		return s[0].State
+	}
+
+	parts := make([]string, 0, len(s))
+	for _, c := range s {
+		parts = append(parts, fmt.Sprintf("%s: %s", c.Name, c.State))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// States fetches the state of all given clusters.
+func (s clusterService) States(ctx context.Context, groupID string, clusters []*mongodbatlas.Cluster) (clusterStates, error) {
+	states := make(clusterStates, 0, len(clusters))
+
+	for _, c := range clusters {
+		cluster, r, err := s.Get(ctx, groupID, c)
+		switch {
+		case err == nil:
+			states = append(states, clusterState{Name: c.Name, State: cluster.StateName, Paused: isTrue(cluster.Paused)})
+		case r != nil && r.StatusCode == http.StatusNotFound:
+			states = append(states, clusterState{Name: c.Name, State: clusterNotFound})
+		default:
+			return nil, errors.Wrapf(err, "cannot get cluster %q", c.Name)
+		}
+	}
+
+	return states, nil
+}
+
+// Delete deletes the cluster. If retainBackups is set, Atlas is asked to keep
+// the cluster's snapshots, which the Go client doesn't support yet.
+func (s clusterService) Delete(ctx context.Context, groupID string, c *mongodbatlas.Cluster, retainBackups bool) (*mongodbatlas.Response, error) {
+	path := ""
+
+	switch {
+	case isServerless(c):
+		path = s.serverlessPath(groupID, c.Name)
+	case retainBackups:
+		path = fmt.Sprintf("groups/%s/clusters/%s?retainBackups=true", groupID, url.PathEscape(c.Name))
+	default:
+		return s.client.Clusters.Delete(ctx, groupID, c.Name)
+	}
+
+	req, err := s.client.NewRequest(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+
+	return s.client.Do(ctx, req, nil)
+}
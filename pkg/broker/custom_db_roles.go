@@ -0,0 +1,99 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func validateCustomDBRoles(p *dynamicplans.Plan) error {
+	names := map[string]bool{}
+	for _, r := range p.CustomDBRoles {
+		if r.RoleName == "" {
+			return errors.New(".customDBRoles[].roleName must not be empty")
+		}
+
+		if names[r.RoleName] {
+			return fmt.Errorf("duplicate custom DB role %q", r.RoleName)
+		}
+		names[r.RoleName] = true
+	}
+
+	return nil
+}
+
+func customDBRoleByName(roles []*mongodbatlas.CustomDBRole, name string) *mongodbatlas.CustomDBRole {
+	for _, r := range roles {
+		if r.RoleName == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// reconcileCustomDBRoles creates or updates the roles declared in the new plan
+// and deletes the ones which were only declared in the old plan. Roles not
+// managed by the broker are left alone.
+func (b Broker) reconcileCustomDBRoles(ctx context.Context, client *mongodbatlas.Client, groupID string, oldRoles []*mongodbatlas.CustomDBRole, newRoles []*mongodbatlas.CustomDBRole) error {
+	logger := b.funcLogger()
+
+	for _, r := range newRoles {
+		_, resp, err := client.CustomDBRoles.Get(ctx, groupID, r.RoleName)
+		switch {
+		case err == nil:
+			// the role name is part of the path and must not be in the body
+			update := *r
+			update.RoleName = ""
+
+			_, _, err = client.CustomDBRoles.Update(ctx, groupID, r.RoleName, &update)
+			if err != nil {
+				return errors.Wrapf(err, "cannot update custom DB role %q", r.RoleName)
+			}
+
+		case resp != nil && resp.StatusCode == http.StatusNotFound:
+			_, _, err = client.CustomDBRoles.Create(ctx, groupID, r)
+			if err != nil {
+				return errors.Wrapf(err, "cannot create custom DB role %q", r.RoleName)
+			}
+
+		default:
+			return errors.Wrapf(err, "cannot get custom DB role %q", r.RoleName)
+		}
+
+		logger.Infow("Reconciled custom DB role", "role", r.RoleName)
+	}
+
+	for _, r := range oldRoles {
+		if customDBRoleByName(newRoles, r.RoleName) != nil {
+			continue
+		}
+
+		resp, err := client.CustomDBRoles.Delete(ctx, groupID, r.RoleName)
+		if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+			return errors.Wrapf(err, "cannot delete custom DB role %q", r.RoleName)
+		}
+
+		logger.Infow("Deleted custom DB role", "role", r.RoleName)
+	}
+
+	return nil
+}
@@ -14,48 +14,72 @@ const (
 	overrideAtlasUserRoles = "overrideAtlasUserRoles"
 )
 
-func (b *Broker) addUserToProject(ctx context.Context, client *mongodbatlas.Client, planContext dynamicplans.Context, p *dynamicplans.Plan) error {
-	email, ok := planContext["email"].(string)
-	if !ok {
-		return fmt.Errorf("email should be string, got %T = %v", planContext["email"], planContext["email"])
-	}
+func init() {
+	RegisterOperation(Operation{
+		Name:        "AddUserToProject",
+		Description: "Invites an Atlas user to the project, or adds an existing one.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"email":     map[string]interface{}{"type": "string", "format": "email"},
+				"password":  map[string]interface{}{"type": "string", "description": "Generated if not set."},
+				"firstName": map[string]interface{}{"type": "string", "default": "Unnamed"},
+				"lastName":  map[string]interface{}{"type": "string", "default": "Unnamed"},
+				"country":   map[string]interface{}{"type": "string", "default": "US"},
+			},
+			"required": []string{"email"},
+		},
+		Handler: addUserToProject,
+	})
+
+	RegisterOperation(Operation{
+		Name:        "RemoveUserFromProject",
+		Description: "Removes an Atlas user from the project.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"email": map[string]interface{}{"type": "string", "format": "email"},
+			},
+			"required": []string{"email"},
+		},
+		Handler: removeUserFromProject,
+	})
+}
 
-	password, ok := planContext["password"].(string)
-	if !ok {
-		var err error
-		password, err = generatePassword()
-		if err != nil {
-			return err
-		}
-	}
+type projectUserParams struct {
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Country   string `json:"country"`
+}
 
-	firstName, ok := planContext["firstName"].(string)
-	if !ok {
-		firstName = "Unnamed"
+func addUserToProject(ctx context.Context, req OperationRequest) error {
+	params := projectUserParams{
+		FirstName: "Unnamed",
+		LastName:  "Unnamed",
+		Country:   "US",
 	}
 
-	lastName, ok := planContext["lastName"].(string)
-	if !ok {
-		lastName = "Unnamed"
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
 	}
 
-	country, ok := planContext["country"].(string)
-	if !ok {
-		country = "US"
+	if params.Password == "" {
+		params.Password, err = generatePassword()
+		if err != nil {
+			return err
+		}
 	}
 
-	roleNames, ok := p.Settings[overrideAtlasUserRoles].([]interface{})
-	if !ok {
-		roleNames = []interface{}{"GROUP_READ_ONLY"}
+	client, p := req.Client, req.Plan
+
+	roleNames, err := projectRoleNames(p)
+	if err != nil {
+		return err
 	}
 
 	roles := make([]mongodbatlas.AtlasRole, 0, len(roleNames))
-	for _, r := range roleNames {
-		role, ok := r.(string)
-		if !ok {
-			return fmt.Errorf("role name must be a string, got %v (%T)", r, r)
-		}
-
+	for _, role := range roleNames {
 		roles = append(roles, mongodbatlas.AtlasRole{
 			GroupID:  p.Project.ID,
 			RoleName: role,
@@ -63,12 +87,12 @@ func (b *Broker) addUserToProject(ctx context.Context, client *mongodbatlas.Clie
 	}
 
 	u := &mongodbatlas.AtlasUser{
-		EmailAddress: email,
-		Password:     password,
-		Country:      country,
-		Username:     email,
-		FirstName:    firstName,
-		LastName:     lastName,
+		EmailAddress: params.Email,
+		Password:     params.Password,
+		Country:      params.Country,
+		Username:     params.Email,
+		FirstName:    params.FirstName,
+		LastName:     params.LastName,
 		Roles:        roles,
 	}
 
@@ -85,7 +109,7 @@ func (b *Broker) addUserToProject(ctx context.Context, client *mongodbatlas.Clie
 	}
 
 	// 409 Conflict: user already exists in the system, need to add them to the project
-	u, _, err = client.AtlasUsers.GetByName(ctx, email)
+	u, _, err = client.AtlasUsers.GetByName(ctx, params.Email)
 	if err != nil {
 		return errors.Wrap(err, "cannot get Atlas user by name")
 	}
@@ -95,31 +119,41 @@ func (b *Broker) addUserToProject(ctx context.Context, client *mongodbatlas.Clie
 	return errors.Wrap(err, "cannot update Atlas user")
 }
 
-func (b *Broker) removeUserFromProject(ctx context.Context, client *mongodbatlas.Client, planContext dynamicplans.Context, p *dynamicplans.Plan) error {
-	email, ok := planContext["email"].(string)
+// projectRoleNames returns the project roles given to users and teams added
+// by custom operations.
+func projectRoleNames(p *dynamicplans.Plan) ([]string, error) {
+	roleNames, ok := p.Settings[overrideAtlasUserRoles].([]interface{})
 	if !ok {
-		return fmt.Errorf("email should be string, got %T = %v", planContext["email"], planContext["email"])
+		return []string{"GROUP_READ_ONLY"}, nil
 	}
 
-	u, _, err := client.AtlasUsers.GetByName(ctx, email)
-	if err != nil {
-		return errors.Wrap(err, "cannot get Atlas user by name")
-	}
+	roles := make([]string, 0, len(roleNames))
+	for _, r := range roleNames {
+		role, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("role name must be a string, got %v (%T)", r, r)
+		}
 
-	_, err = client.Projects.RemoveUserFromProject(ctx, p.Project.ID, u.ID)
+		roles = append(roles, role)
+	}
 
-	return errors.Wrap(err, "cannot remove Atlas user from Project")
+	return roles, nil
 }
 
-func (b *Broker) performOperation(ctx context.Context, client *mongodbatlas.Client, planContext dynamicplans.Context, p *dynamicplans.Plan, op string) error {
-	switch op {
-	case "AddUserToProject":
-		return b.addUserToProject(ctx, client, planContext, p)
+func removeUserFromProject(ctx context.Context, req OperationRequest) error {
+	params := projectUserParams{}
 
-	case "RemoveUserFromProject":
-		return b.removeUserFromProject(ctx, client, planContext, p)
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
 
-	default:
-		return fmt.Errorf("unknown operation %q", op)
+	u, _, err := req.Client.AtlasUsers.GetByName(ctx, params.Email)
+	if err != nil {
+		return errors.Wrap(err, "cannot get Atlas user by name")
 	}
+
+	_, err = req.Client.Projects.RemoveUserFromProject(ctx, req.Plan.Project.ID, u.ID)
+
+	return errors.Wrap(err, "cannot remove Atlas user from Project")
 }
@@ -0,0 +1,47 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamicplans
+
+import "go.mongodb.org/atlas/mongodbatlas"
+
+// CloudProviderAccessRole lets Atlas assume an IAM role in the customer's
+// cloud account. The Atlas-generated fields are filled in by the broker.
+type CloudProviderAccessRole struct {
+	ProviderName      string `json:"providerName,omitempty"`
+	IAMAssumedRoleARN string `json:"iamAssumedRoleArn,omitempty"`
+
+	RoleID                     string `json:"roleId,omitempty"`
+	AtlasAWSAccountARN         string `json:"atlasAWSAccountArn,omitempty"`
+	AtlasAssumedRoleExternalID string `json:"atlasAssumedRoleExternalId,omitempty"`
+	AuthorizedDate             string `json:"authorizedDate,omitempty"`
+}
+
+// EncryptionAtRest is the customer key management configuration of the project.
+type EncryptionAtRest struct {
+	AwsKms         *AwsKms                      `json:"awsKms,omitempty"`
+	AzureKeyVault  *mongodbatlas.AzureKeyVault  `json:"azureKeyVault,omitempty"`
+	GoogleCloudKms *mongodbatlas.GoogleCloudKms `json:"googleCloudKms,omitempty"`
+}
+
+// AwsKms is mongodbatlas.AwsKms with the cloud provider access role used to
+// reach the key, which the Go client doesn't support yet.
+type AwsKms struct {
+	Enabled             *bool  `json:"enabled,omitempty"`
+	AccessKeyID         string `json:"accessKeyID,omitempty"`
+	SecretAccessKey     string `json:"secretAccessKey,omitempty"`
+	CustomerMasterKeyID string `json:"customerMasterKeyID,omitempty"`
+	Region              string `json:"region,omitempty"`
+	RoleID              string `json:"roleId,omitempty"`
+}
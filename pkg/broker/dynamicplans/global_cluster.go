@@ -0,0 +1,27 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamicplans
+
+import (
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// GlobalCluster represents the Global Writes configuration of a geo-sharded cluster
+type GlobalCluster struct {
+	// ClusterName defaults to the plan's primary cluster
+	ClusterName        string                           `json:"clusterName,omitempty"`
+	CustomZoneMappings []mongodbatlas.CustomZoneMapping `json:"customZoneMappings,omitempty"`
+	ManagedNamespaces  []mongodbatlas.ManagedNamespace  `json:"managedNamespaces,omitempty"`
+}
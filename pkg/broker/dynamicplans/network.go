@@ -0,0 +1,25 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamicplans
+
+import "go.mongodb.org/atlas/mongodbatlas"
+
+// NetworkPeering is a peering connection together with the Atlas network
+// container on the Atlas side of it. The container ID is filled in by the
+// broker once the container is created.
+type NetworkPeering struct {
+	Container *mongodbatlas.Container `json:"container,omitempty"`
+	Peer      *mongodbatlas.Peer      `json:"peer,omitempty"`
+}
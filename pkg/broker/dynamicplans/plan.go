@@ -30,9 +30,27 @@ type Plan struct {
 	APIKey        *credentials.APIKey                   `json:"apiKey,omitempty"`
 	Project       *mongodbatlas.Project                 `json:"project,omitempty"`
 	Cluster       *mongodbatlas.Cluster                 `json:"cluster,omitempty"`
+	Clusters      []*mongodbatlas.Cluster               `json:"clusters,omitempty"`
+	CustomDBRoles []*mongodbatlas.CustomDBRole          `json:"customDBRoles,omitempty"`
 	DatabaseUsers []*mongodbatlas.DatabaseUser          `json:"databaseUsers,omitempty"`
 	IPAccessLists []*mongodbatlas.ProjectIPAccessList   `json:"ipAccessLists,omitempty"`
 	Integrations  []*mongodbatlas.ThirdPartyIntegration `json:"integrations,omitempty"`
+	AlertConfigs  []*mongodbatlas.AlertConfiguration    `json:"alertConfigs,omitempty"`
+	Teams         []*Team                               `json:"teams,omitempty"`
+
+	ProcessArgs      *ProcessArgs                                    `json:"processArgs,omitempty"`
+	BackupPolicy     *mongodbatlas.CloudProviderSnapshotBackupPolicy `json:"backupPolicy,omitempty"`
+	GlobalClusters   []*GlobalCluster                                `json:"globalClusters,omitempty"`
+	SearchIndexes    []*SearchIndex                                  `json:"searchIndexes,omitempty"`
+	NetworkPeering   []*NetworkPeering                               `json:"networkPeering,omitempty"`
+	PrivateEndpoints []*mongodbatlas.PrivateEndpointConnection       `json:"privateEndpoints,omitempty"`
+
+	CloudProviderAccessRoles []*CloudProviderAccessRole `json:"cloudProviderAccessRoles,omitempty"`
+	EncryptionAtRest         *EncryptionAtRest          `json:"encryptionAtRest,omitempty"`
+
+	MaintenanceWindow *mongodbatlas.MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+	Auditing          *mongodbatlas.Auditing          `json:"auditing,omitempty"`
+	ProjectSettings   *ProjectSettings                `json:"projectSettings,omitempty"`
 
 	Settings map[string]interface{} `json:"settings,omitempty"`
 
@@ -40,6 +58,40 @@ type Plan struct {
 	IPWhitelists []*mongodbatlas.ProjectIPWhitelist `json:"ipWhitelists,omitempty"`
 }
 
+// NormalizeClusters makes Cluster the primary (first) cluster of the plan and
+// Clusters the additional ones, so plans may declare either or both.
+func (p *Plan) NormalizeClusters() {
+	if p.Cluster == nil && len(p.Clusters) > 0 {
+		p.Cluster = p.Clusters[0]
+		p.Clusters = p.Clusters[1:]
+	}
+}
+
+// AllClusters returns the primary cluster followed by the additional ones.
+func (p *Plan) AllClusters() []*mongodbatlas.Cluster {
+	if p.Cluster == nil {
+		return p.Clusters
+	}
+
+	return append([]*mongodbatlas.Cluster{p.Cluster}, p.Clusters...)
+}
+
+// ClusterByName returns the plan's cluster with the given name, or the primary
+// cluster if name is empty.
+func (p *Plan) ClusterByName(name string) *mongodbatlas.Cluster {
+	if name == "" {
+		return p.Cluster
+	}
+
+	for _, c := range p.AllClusters() {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
 func (p *Plan) SafeCopy() Plan {
 	b, err := json.Marshal(p)
 	if err != nil {
@@ -56,6 +108,32 @@ func (p *Plan) SafeCopy() Plan {
 		safe.APIKey.PrivateKey = "*REDACTED*"
 	}
 
+	if e := safe.EncryptionAtRest; e != nil {
+		if e.AwsKms != nil && e.AwsKms.SecretAccessKey != "" {
+			e.AwsKms.SecretAccessKey = "*REDACTED*"
+		}
+
+		if e.AzureKeyVault != nil && e.AzureKeyVault.Secret != "" {
+			e.AzureKeyVault.Secret = "*REDACTED*"
+		}
+
+		if e.GoogleCloudKms != nil && e.GoogleCloudKms.ServiceAccountKey != "" {
+			e.GoogleCloudKms.ServiceAccountKey = "*REDACTED*"
+		}
+	}
+
+	for _, a := range safe.AlertConfigs {
+		for i := range a.Notifications {
+			n := &a.Notifications[i]
+			redact(&n.APIToken)
+			redact(&n.DatadogAPIKey)
+			redact(&n.FlowdockAPIToken)
+			redact(&n.OpsGenieAPIKey)
+			redact(&n.ServiceKey)
+			redact(&n.VictorOpsAPIKey)
+		}
+	}
+
 	for i := range safe.DatabaseUsers {
 		if safe.DatabaseUsers[i].Password != "" {
 			safe.DatabaseUsers[i].Password = "*REDACTED*"
@@ -65,6 +143,12 @@ func (p *Plan) SafeCopy() Plan {
 	return safe
 }
 
+func redact(s *string) {
+	if *s != "" {
+		*s = "*REDACTED*"
+	}
+}
+
 func (p Plan) String() string {
 	s, err := json.Marshal(p)
 	if err != nil {
@@ -0,0 +1,30 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamicplans
+
+// ProcessArgs is the advanced configuration of a cluster. It extends
+// mongodbatlas.ProcessArgs with the default read and write concerns, which
+// the Go client doesn't support yet.
+type ProcessArgs struct {
+	DefaultReadConcern               string `json:"defaultReadConcern,omitempty"`
+	DefaultWriteConcern              string `json:"defaultWriteConcern,omitempty"`
+	FailIndexKeyTooLong              *bool  `json:"failIndexKeyTooLong,omitempty"`
+	JavascriptEnabled                *bool  `json:"javascriptEnabled,omitempty"`
+	MinimumEnabledTLSProtocol        string `json:"minimumEnabledTlsProtocol,omitempty"`
+	NoTableScan                      *bool  `json:"noTableScan,omitempty"`
+	OplogSizeMB                      *int64 `json:"oplogSizeMB,omitempty"`
+	SampleSizeBIConnector            *int64 `json:"sampleSizeBIConnector,omitempty"`
+	SampleRefreshIntervalBIConnector *int64 `json:"sampleRefreshIntervalBIConnector,omitempty"`
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamicplans
+
+import "go.mongodb.org/atlas/mongodbatlas"
+
+// SearchIndex is an Atlas Search index on one of the plan's clusters, the
+// primary one if ClusterName is empty.
+type SearchIndex struct {
+	ClusterName    string                     `json:"clusterName,omitempty"`
+	Name           string                     `json:"name,omitempty"`
+	Database       string                     `json:"database,omitempty"`
+	CollectionName string                     `json:"collectionName,omitempty"`
+	Analyzer       string                     `json:"analyzer,omitempty"`
+	SearchAnalyzer string                     `json:"searchAnalyzer,omitempty"`
+	Mappings       *mongodbatlas.IndexMapping `json:"mappings,omitempty"`
+}
+
+// Index returns the index definition in the form expected by the Atlas API.
+func (s *SearchIndex) Index() *mongodbatlas.SearchIndex {
+	return &mongodbatlas.SearchIndex{
+		Name:           s.Name,
+		Database:       s.Database,
+		CollectionName: s.CollectionName,
+		Analyzer:       s.Analyzer,
+		SearchAnalyzer: s.SearchAnalyzer,
+		Mappings:       s.Mappings,
+	}
+}
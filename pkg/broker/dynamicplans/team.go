@@ -0,0 +1,23 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamicplans
+
+// Team is an existing Atlas team of the organization which is given access to
+// the plan's project. It can be referred to either by ID or by name.
+type Team struct {
+	TeamID    string   `json:"teamId,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	RoleNames []string `json:"roleNames,omitempty"`
+}
@@ -0,0 +1,336 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func init() {
+	RegisterOperation(Operation{
+		Name:        "AuthorizeCloudProviderAccess",
+		Description: "Authorizes a cloud provider access role of the plan once its IAM role trusts Atlas, and enables AWS KMS encryption at rest waiting for it.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"roleId": map[string]interface{}{
+					"type":        "string",
+					"description": "Defaults to the AWS role of the plan.",
+				},
+				"iamAssumedRoleArn": map[string]interface{}{
+					"type":        "string",
+					"description": "Defaults to the iamAssumedRoleArn of the role in the plan.",
+				},
+			},
+		},
+		Async:    true,
+		Handler:  authorizeCloudProviderAccess,
+		Progress: authorizeCloudProviderAccessProgress,
+	})
+}
+
+func validateEncryption(p *dynamicplans.Plan) error {
+	for i, r := range p.CloudProviderAccessRoles {
+		if r.ProviderName != "AWS" {
+			return fmt.Errorf(".cloudProviderAccessRoles[%d].providerName must be AWS, got %q", i, r.ProviderName)
+		}
+	}
+
+	e := p.EncryptionAtRest
+	if e == nil {
+		e = &dynamicplans.EncryptionAtRest{}
+	}
+
+	if e.AwsKms != nil && isTrue(e.AwsKms.Enabled) && e.AwsKms.AccessKeyID == "" && e.AwsKms.RoleID == "" && awsAccessRole(p) == nil {
+		return errors.New(".encryptionAtRest.awsKms requires either accessKeyID or an AWS entry in .cloudProviderAccessRoles")
+	}
+
+	for _, c := range p.AllClusters() {
+		enabled := false
+
+		switch c.EncryptionAtRestProvider {
+		case "", "NONE":
+			continue
+		case "AWS":
+			enabled = e.AwsKms != nil && isTrue(e.AwsKms.Enabled)
+		case "AZURE":
+			enabled = e.AzureKeyVault != nil && isTrue(e.AzureKeyVault.Enabled)
+		case "GCP":
+			enabled = e.GoogleCloudKms != nil && isTrue(e.GoogleCloudKms.Enabled)
+		default:
+			return fmt.Errorf("cluster %q: unknown encryptionAtRestProvider %q", c.Name, c.EncryptionAtRestProvider)
+		}
+
+		if !enabled {
+			return fmt.Errorf("cluster %q uses encryptionAtRestProvider %s, which is not enabled in .encryptionAtRest", c.Name, c.EncryptionAtRestProvider)
+		}
+	}
+
+	return nil
+}
+
+func awsAccessRole(p *dynamicplans.Plan) *dynamicplans.CloudProviderAccessRole {
+	for _, r := range p.CloudProviderAccessRoles {
+		if r.ProviderName == "AWS" {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// createCloudProviderAccess creates the plan's cloud provider access roles.
+// Atlas only issues the AWS account ARN and external ID the IAM role has to
+// trust once a role is created, so the roles are authorized in a second step
+// by the AuthorizeCloudProviderAccess operation. The Go client doesn't cover
+// this API yet.
+func (b Broker) createCloudProviderAccess(ctx context.Context, client *mongodbatlas.Client, groupID string, p *dynamicplans.Plan) error {
+	logger := b.funcLogger()
+	path := fmt.Sprintf("groups/%s/cloudProviderAccess", groupID)
+
+	for _, r := range p.CloudProviderAccessRoles {
+		req, err := client.NewRequest(ctx, http.MethodPost, path, &dynamicplans.CloudProviderAccessRole{ProviderName: r.ProviderName})
+		if err != nil {
+			return errors.Wrap(err, "cannot create request")
+		}
+
+		created := dynamicplans.CloudProviderAccessRole{}
+		_, err = client.Do(ctx, req, &created)
+		if err != nil {
+			return errors.Wrap(err, "cannot create Cloud Provider Access Role")
+		}
+
+		r.RoleID = created.RoleID
+		r.AtlasAWSAccountARN = created.AtlasAWSAccountARN
+		r.AtlasAssumedRoleExternalID = created.AtlasAssumedRoleExternalID
+
+		logger.Infow(
+			"Created cloud provider access role, waiting for AuthorizeCloudProviderAccess",
+			"roleID", r.RoleID, "atlasAWSAccountArn", r.AtlasAWSAccountARN, "externalID", r.AtlasAssumedRoleExternalID,
+		)
+	}
+
+	return nil
+}
+
+// pendingKmsRole returns the AWS role of the plan if AWS KMS has to wait for
+// its authorization, or nil.
+func pendingKmsRole(p *dynamicplans.Plan) *dynamicplans.CloudProviderAccessRole {
+	e := p.EncryptionAtRest
+	if e == nil || e.AwsKms == nil || !isTrue(e.AwsKms.Enabled) || e.AwsKms.AccessKeyID != "" || e.AwsKms.RoleID != "" {
+		return nil
+	}
+
+	r := awsAccessRole(p)
+	if r == nil || r.AuthorizedDate != "" {
+		return nil
+	}
+
+	return r
+}
+
+// withoutPendingEncryption returns the cluster to send to Atlas. Clusters
+// using AWS KMS through a role which isn't authorized yet are created without
+// customer key management, which AuthorizeCloudProviderAccess enables later.
+func withoutPendingEncryption(p *dynamicplans.Plan, c *mongodbatlas.Cluster) *mongodbatlas.Cluster {
+	if c.EncryptionAtRestProvider != "AWS" || pendingKmsRole(p) == nil {
+		return c
+	}
+
+	pending := *c
+	pending.EncryptionAtRestProvider = "NONE"
+
+	return &pending
+}
+
+// configureEncryptionAtRest sets up customer key management for the project.
+// It has to happen before any cluster using it is created. An AWS KMS config
+// without credentials uses the plan's AWS cloud provider access role.
+func (b Broker) configureEncryptionAtRest(ctx context.Context, client *mongodbatlas.Client, groupID string, p *dynamicplans.Plan) error {
+	e := p.EncryptionAtRest
+	if e == nil {
+		return nil
+	}
+
+	config := *e
+
+	if pendingKmsRole(p) != nil {
+		// configured by AuthorizeCloudProviderAccess
+		config.AwsKms = nil
+	} else if e.AwsKms != nil && isTrue(e.AwsKms.Enabled) && e.AwsKms.AccessKeyID == "" && e.AwsKms.RoleID == "" {
+		if r := awsAccessRole(p); r != nil {
+			e.AwsKms.RoleID = r.RoleID
+		}
+	}
+
+	if config.AwsKms == nil && config.AzureKeyVault == nil && config.GoogleCloudKms == nil {
+		return nil
+	}
+
+	req, err := client.NewRequest(ctx, http.MethodPatch, fmt.Sprintf("groups/%s/encryptionAtRest", groupID), &config)
+	if err != nil {
+		return errors.Wrap(err, "cannot create request")
+	}
+
+	_, err = client.Do(ctx, req, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot configure Encryption at Rest")
+	}
+
+	b.funcLogger().Infow("Configured encryption at rest", "groupID", groupID)
+
+	return nil
+}
+
+type authorizeCloudProviderAccessParams struct {
+	RoleID            string `json:"roleId"`
+	IAMAssumedRoleARN string `json:"iamAssumedRoleArn"`
+}
+
+func authorizeCloudProviderAccess(ctx context.Context, req OperationRequest) error {
+	params := authorizeCloudProviderAccessParams{}
+
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
+
+	return req.broker.authorizeAccessRole(ctx, req.Client, req.InstanceID, req.Plan, params)
+}
+
+// authorizeAccessRole authorizes a cloud provider access role of the plan and
+// enables AWS KMS if it was waiting for the role, then stores the plan.
+func (b *Broker) authorizeAccessRole(ctx context.Context, client *mongodbatlas.Client, instanceID string, p *dynamicplans.Plan, params authorizeCloudProviderAccessParams) error {
+	groupID := p.Project.ID
+
+	var r *dynamicplans.CloudProviderAccessRole
+	if params.RoleID == "" {
+		r = awsAccessRole(p)
+	}
+
+	for _, role := range p.CloudProviderAccessRoles {
+		if params.RoleID != "" && role.RoleID == params.RoleID {
+			r = role
+		}
+	}
+
+	if r == nil || r.RoleID == "" {
+		return fmt.Errorf("cloud provider access role %q not found in plan", params.RoleID)
+	}
+
+	if params.IAMAssumedRoleARN != "" {
+		r.IAMAssumedRoleARN = params.IAMAssumedRoleARN
+	}
+
+	if r.IAMAssumedRoleARN == "" {
+		return errors.New("iamAssumedRoleArn is required")
+	}
+
+	pending := pendingKmsRole(p) == r
+
+	authorize := &dynamicplans.CloudProviderAccessRole{
+		ProviderName:      r.ProviderName,
+		IAMAssumedRoleARN: r.IAMAssumedRoleARN,
+	}
+
+	path := fmt.Sprintf("groups/%s/cloudProviderAccess/%s", groupID, url.PathEscape(r.RoleID))
+
+	httpReq, err := client.NewRequest(ctx, http.MethodPatch, path, authorize)
+	if err != nil {
+		return errors.Wrap(err, "cannot create request")
+	}
+
+	authorized := dynamicplans.CloudProviderAccessRole{}
+
+	_, err = client.Do(ctx, httpReq, &authorized)
+	if err != nil {
+		return errors.Wrapf(
+			err,
+			"cannot authorize Cloud Provider Access Role, %s must trust %s with external ID %s",
+			r.IAMAssumedRoleARN, r.AtlasAWSAccountARN, r.AtlasAssumedRoleExternalID,
+		)
+	}
+
+	r.AuthorizedDate = authorized.AuthorizedDate
+	if r.AuthorizedDate == "" {
+		r.AuthorizedDate = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	b.funcLogger().Infow("Authorized cloud provider access role", "roleID", r.RoleID, "iamAssumedRoleArn", r.IAMAssumedRoleARN)
+
+	if pending {
+		err = b.enableKmsEncryption(ctx, client, p)
+	}
+
+	// the role is authorized even if enabling encryption failed
+	if errSave := b.savePlan(ctx, instanceID, p); errSave != nil && err == nil {
+		err = errSave
+	}
+
+	return err
+}
+
+// enableKmsEncryption configures AWS KMS through the now authorized role and
+// turns it on for the clusters declaring it.
+func (b *Broker) enableKmsEncryption(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) error {
+	err := b.configureEncryptionAtRest(ctx, client, p.Project.ID, p)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range p.AllClusters() {
+		if c.EncryptionAtRestProvider != "AWS" {
+			continue
+		}
+
+		_, _, err = client.Clusters.Update(ctx, p.Project.ID, c.Name, &mongodbatlas.Cluster{EncryptionAtRestProvider: "AWS"})
+		if err != nil {
+			return errors.Wrapf(err, "cannot enable encryption at rest for cluster %q", c.Name)
+		}
+
+		b.funcLogger().Infow("Enabled AWS KMS encryption at rest", "cluster", c.Name)
+	}
+
+	return nil
+}
+
+// authorizeCloudProviderAccessProgress waits for the clusters to be IDLE
+// again after encryption at rest has been enabled.
+func authorizeCloudProviderAccessProgress(ctx context.Context, req OperationRequest) (domain.LastOperation, error) {
+	states, err := clusterService{req.Client}.States(ctx, req.Plan.Project.ID, req.Plan.AllClusters())
+	if err != nil {
+		return domain.LastOperation{}, err
+	}
+
+	if !states.all("IDLE") {
+		return domain.LastOperation{
+			State:       domain.InProgress,
+			Description: "enabling encryption at rest: " + states.String(),
+		}, nil
+	}
+
+	return domain.LastOperation{
+		State:       domain.Succeeded,
+		Description: "cloud provider access role authorized",
+	}, nil
+}
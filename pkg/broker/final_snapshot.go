@@ -0,0 +1,184 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Plan settings controlling the on-demand snapshot taken before a cluster is deleted.
+const (
+	finalSnapshot              = "finalSnapshot"
+	finalSnapshotRetentionDays = "finalSnapshotRetentionDays"
+)
+
+const defaultFinalSnapshotRetentionDays = 30
+
+// finalSnapshotRecord is kept in the state storage so that operators can find
+// the snapshots after the instance itself is gone.
+type finalSnapshotRecord struct {
+	InstanceID string        `json:"instanceId"`
+	OrgID      string        `json:"orgId"`
+	ProjectID  string        `json:"projectId"`
+	Snapshots  []snapshotRef `json:"snapshots"`
+	DeletedAt  time.Time     `json:"deletedAt"`
+}
+
+type snapshotRef struct {
+	ClusterName string `json:"clusterName"`
+	SnapshotID  string `json:"snapshotId"`
+	CreatedAt   string `json:"createdAt,omitempty"`
+	ExpiresAt   string `json:"expiresAt,omitempty"`
+}
+
+func finalSnapshotName(instanceID string) string {
+	return "final-snapshot-" + instanceID
+}
+
+// takeFinalSnapshots starts an on-demand snapshot of every cluster and
+// returns the snapshot IDs by cluster name.
+func (b Broker) takeFinalSnapshots(ctx context.Context, client *mongodbatlas.Client, instanceID string, p *dynamicplans.Plan) (map[string]string, error) {
+	for _, c := range p.AllClusters() {
+		if isServerless(c) || isSharedTier(c) {
+			return nil, fmt.Errorf("final snapshot is only supported for dedicated clusters, %q is not", c.Name)
+		}
+
+		if !isTrue(c.ProviderBackupEnabled) {
+			return nil, fmt.Errorf("final snapshot requires providerBackupEnabled, which is disabled for %q", c.Name)
+		}
+	}
+
+	retention, err := settingInt(p.Settings, finalSnapshotRetentionDays, defaultFinalSnapshotRetentionDays)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := map[string]string{}
+	for _, c := range p.AllClusters() {
+		params := &mongodbatlas.SnapshotReqPathParameters{
+			GroupID:     p.Project.ID,
+			ClusterName: c.Name,
+		}
+
+		request := &mongodbatlas.CloudProviderSnapshot{
+			Description:     fmt.Sprintf("Final snapshot of service instance %s", instanceID),
+			RetentionInDays: retention,
+		}
+
+		snapshot, _, err := client.CloudProviderSnapshots.Create(ctx, params, request)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create final snapshot of %q", c.Name)
+		}
+
+		snapshots[c.Name] = snapshot.ID
+	}
+
+	return snapshots, nil
+}
+
+// finalSnapshotProgress checks the snapshots started by Deprovision and starts
+// the cluster deletion once all of them have completed.
+func (b Broker) finalSnapshotProgress(ctx context.Context, client *mongodbatlas.Client, instanceID string, op operation, timeout time.Duration, p *dynamicplans.Plan) (domain.LastOperation, error) {
+	logger := b.funcLogger().With("instance_id", instanceID, "snapshots", op.Snapshots)
+
+	completed := make([]*mongodbatlas.CloudProviderSnapshot, 0, len(op.Snapshots))
+	statuses := make([]string, 0, len(op.Snapshots))
+
+	for _, c := range p.AllClusters() {
+		params := &mongodbatlas.SnapshotReqPathParameters{
+			GroupID:     p.Project.ID,
+			ClusterName: c.Name,
+			SnapshotID:  op.Snapshots[c.Name],
+		}
+
+		snapshot, _, err := client.CloudProviderSnapshots.GetOneCloudProviderSnapshot(ctx, params)
+		if err != nil {
+			return domain.LastOperation{}, errors.Wrapf(err, "cannot get final snapshot of %q", c.Name)
+		}
+
+		switch snapshot.Status {
+		case "completed":
+			completed = append(completed, snapshot)
+
+		case "failed":
+			return domain.LastOperation{
+				State: domain.Failed,
+				Description: fmt.Sprintf(
+					"final snapshot %s of %q failed, the clusters were not deleted, check the Atlas activity feed for details: %s",
+					snapshot.ID, c.Name, b.GetActivityFeedURL(p.Project.ID),
+				),
+			}, nil
+
+		default:
+			statuses = append(statuses, fmt.Sprintf("final snapshot %s of %q is %s", snapshot.ID, c.Name, snapshot.Status))
+		}
+	}
+
+	if len(statuses) > 0 {
+		return b.inProgress(op, timeout, p, strings.Join(statuses, ", ")), nil
+	}
+
+	b.recordFinalSnapshots(ctx, instanceID, p, completed)
+	b.deleteClusters(ctx, client, p, true)
+
+	logger.Infow("Final snapshots completed, started Atlas Cluster deletion process")
+
+	return domain.LastOperation{
+		State:       domain.InProgress,
+		Description: "final snapshots completed, deleting clusters",
+	}, nil
+}
+
+func (b Broker) recordFinalSnapshots(ctx context.Context, instanceID string, p *dynamicplans.Plan, snapshots []*mongodbatlas.CloudProviderSnapshot) {
+	logger := b.funcLogger().With("instance_id", instanceID)
+
+	record := finalSnapshotRecord{
+		InstanceID: instanceID,
+		OrgID:      p.Project.OrgID,
+		ProjectID:  p.Project.ID,
+		DeletedAt:  time.Now().UTC(),
+	}
+
+	for i, c := range p.AllClusters() {
+		record.Snapshots = append(record.Snapshots, snapshotRef{
+			ClusterName: c.Name,
+			SnapshotID:  snapshots[i].ID,
+			CreatedAt:   snapshots[i].CreatedAt,
+			ExpiresAt:   snapshots[i].ExpiresAt,
+		})
+	}
+
+	logger.Infow("Recording final snapshots", "record", record)
+
+	state, err := b.getState(ctx, p.Project.OrgID)
+	if err != nil {
+		logger.Errorw("Failed to get state storage", "error", err)
+
+		return
+	}
+
+	_, err = state.PutValue(ctx, finalSnapshotName(instanceID), record)
+	if err != nil {
+		logger.Errorw("Failed to record final snapshots in maintenance store", "error", err)
+	}
+}
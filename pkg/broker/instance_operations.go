@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
 	"github.com/mongodb/atlas-osb/pkg/broker/statestorage"
@@ -66,6 +67,47 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details domain
 		return
 	}
 
+	// validate the whole plan before creating anything in Atlas
+	err = validatePlan(dp)
+	if err != nil {
+		err = apiresponses.NewFailureResponse(err, http.StatusBadRequest, "provision")
+
+		return
+	}
+
+	state, err := b.getState(ctx, dp.Project.OrgID)
+	if err != nil {
+		return
+	}
+
+	created := []*mongodbatlas.Cluster{}
+	createdProject, stored := false, false
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// the platform forgets about a failed provision, don't leave clusters behind
+		for _, c := range created {
+			_, errDel := clusterService{client}.Delete(ctx, dp.Project.ID, c, false)
+			if errDel != nil {
+				logger.Errorw("Failed to delete Atlas cluster after failed provision", "error", errDel, "cluster", c.Name)
+			}
+		}
+
+		// a project can only be deleted once its clusters are gone
+		if createdProject && len(created) == 0 {
+			b.deleteCreatedProject(ctx, client, dp)
+		}
+
+		if stored {
+			_ = state.DeleteOne(ctx, instanceID)
+			_ = state.DeleteOne(ctx, platformContextName(instanceID))
+			_ = state.DeleteOne(ctx, scheduleRecordName(instanceID))
+		}
+	}()
+
 	if dp.Project.ID == "" {
 		var newp *mongodbatlas.Project
 		newp, err = b.createResources(ctx, client, dp)
@@ -74,6 +116,7 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details domain
 		}
 
 		dp.Project.ID = newp.ID
+		createdProject = true
 	}
 
 	// Async needs to be supported for provisioning to work.
@@ -99,11 +142,6 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details domain
 		Parameters:   planEnc,
 	}
 
-	state, err := b.getState(ctx, dp.Project.OrgID)
-	if err != nil {
-		return
-	}
-
 	v, err := state.Put(ctx, instanceID, &s)
 	if err != nil {
 		logger.Errorw("Error during provision, broker maintenance:", "err", err)
@@ -111,46 +149,78 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details domain
 		return
 	}
 	logger.Infow("Inserted new state value", "v", v)
+	stored = true
 
-	defer func() {
-		if err != nil {
-			_ = state.DeleteOne(ctx, instanceID)
-		}
-	}()
-
-	// Create a new Atlas cluster from the generated definition
-	resultingCluster, _, err := client.Clusters.Create(ctx, dp.Project.ID, dp.Cluster)
+	// restores from other instances are only allowed within the same tenant
+	err = b.putPlatformContext(ctx, dp.Project.OrgID, instanceID, details.RawContext)
 	if err != nil {
-		logger.Errorw("Failed to create Atlas cluster", "error", err, "cluster", dp.Cluster)
+		return
+	}
 
+	err = trackSchedules(ctx, state, instanceID, dp)
+	if err != nil {
 		return
 	}
 
-	logger.Infow("Successfully started Atlas creation process", "cluster", resultingCluster)
+	// Create new Atlas clusters from the generated definition
+	for _, c := range dp.AllClusters() {
+		var resultingCluster *mongodbatlas.Cluster
+		resultingCluster, _, err = clusterService{client}.Create(ctx, dp.Project.ID, withoutPendingEncryption(dp, c))
+		if err != nil {
+			logger.Errorw("Failed to create Atlas cluster", "error", err, "cluster", c)
+
+			return
+		}
+
+		created = append(created, c)
+		logger.Infow("Successfully started Atlas creation process", "cluster", resultingCluster)
+	}
 
 	return domain.ProvisionedServiceSpec{
 		IsAsync:       true,
-		OperationData: operationProvision,
-		DashboardURL:  b.GetDashboardURL(dp.Project.ID, resultingCluster.Name),
+		OperationData: b.operationData(newOperation(operationProvision)),
+		DashboardURL:  b.GetDashboardURL(dp.Project.ID, dp.Cluster.Name),
 	}, nil
 }
 
-func (b *Broker) createResources(ctx context.Context, client *mongodbatlas.Client, dp *dynamicplans.Plan) (*mongodbatlas.Project, error) {
+// createResources creates the project of the plan with everything it
+// declares. If anything fails, the project is deleted again.
+func (b *Broker) createResources(ctx context.Context, client *mongodbatlas.Client, dp *dynamicplans.Plan) (p *mongodbatlas.Project, err error) {
 	logger := b.funcLogger()
 
-	p, _, err := client.Projects.Create(ctx, dp.Project)
+	p, _, err = client.Projects.Create(ctx, dp.Project)
 	if err != nil {
 		logger.Errorw("Cannot create project", "error", err, "project", dp.Project)
 
 		return nil, errors.Wrap(err, "cannot create Atlas project")
 	}
 
+	projectID := p.ID
+
+	defer func() {
+		if err != nil {
+			dp.Project.ID = projectID
+			b.deleteCreatedProject(ctx, client, dp)
+			dp.Project.ID = ""
+		}
+	}()
+
+	// custom roles must exist before any database user can reference them
+	for _, r := range dp.CustomDBRoles {
+		_, _, err := client.CustomDBRoles.Create(ctx, p.ID, r)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create Custom DB Role")
+		}
+	}
+
 	for _, u := range dp.DatabaseUsers {
 		if len(u.Scopes) == 0 {
-			u.Scopes = append(u.Scopes, mongodbatlas.Scope{
-				Name: dp.Cluster.Name,
-				Type: "CLUSTER",
-			})
+			for _, c := range dp.AllClusters() {
+				u.Scopes = append(u.Scopes, mongodbatlas.Scope{
+					Name: c.Name,
+					Type: "CLUSTER",
+				})
+			}
 		}
 
 		_, _, err := client.DatabaseUsers.Create(ctx, p.ID, u)
@@ -181,9 +251,62 @@ func (b *Broker) createResources(ctx context.Context, client *mongodbatlas.Clien
 		}
 	}
 
+	err = b.createNetwork(ctx, client, p.ID, dp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.createCloudProviderAccess(ctx, client, p.ID, dp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.configureEncryptionAtRest(ctx, client, p.ID, dp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.applyProjectConfig(ctx, client, p.ID, dp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.reconcileAlertConfigs(ctx, client, p.ID, nil, dp.AlertConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.reconcileTeams(ctx, client, p, nil, dp.Teams)
+	if err != nil {
+		return nil, err
+	}
+
 	return p, nil
 }
 
+// deleteCreatedProject deletes a project created by a failed provision, with
+// its network resources. Atlas deletes peerings in the background and keeps
+// their containers until then, in which case the project is left behind and
+// has to be deleted by hand.
+func (b *Broker) deleteCreatedProject(ctx context.Context, client *mongodbatlas.Client, dp *dynamicplans.Plan) {
+	logger := b.funcLogger().With("projectID", dp.Project.ID)
+
+	b.startNetworkDeletion(ctx, client, dp)
+
+	err := b.deleteNetwork(ctx, client, dp)
+	if err == nil {
+		_, err = client.Projects.Delete(ctx, dp.Project.ID)
+	}
+
+	if err != nil {
+		logger.Errorw("Failed to delete Atlas project after failed provision", "error", err)
+
+		return
+	}
+
+	logger.Infow("Deleted Atlas project after failed provision")
+}
+
 // Update will change the configuration of an existing Atlas cluster asynchronously.
 func (b Broker) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, asyncAllowed bool) (spec domain.UpdateServiceSpec, err error) {
 	logger := b.funcLogger().With("instance_id", instanceID)
@@ -222,37 +345,34 @@ func (b Broker) Update(ctx context.Context, instanceID string, details domain.Up
 
 	// special case: pause/unpause
 	if paused, ok := planContext["paused"].(bool); ok {
-		request := &mongodbatlas.Cluster{
-			Paused: &paused,
-		}
-
-		_, _, err = client.Clusters.Update(ctx, oldPlan.Project.ID, oldPlan.Cluster.Name, request)
+		err = pauseClusters(ctx, client, oldPlan, paused)
 
 		return domain.UpdateServiceSpec{
 			IsAsync:       true,
-			OperationData: operationUpdate,
+			OperationData: b.operationData(newOperation(operationUpdate)),
 			DashboardURL:  b.GetDashboardURL(oldPlan.Project.ID, oldPlan.Cluster.Name),
-		}, errors.Wrap(err, "cannot update Cluster")
+		}, err
 	}
 
 	// special case: perform update operations
-	if op, ok := planContext["op"].(string); ok {
-		err = b.performOperation(ctx, client, planContext, oldPlan, op)
+	if name, ok := planContext["op"].(string); ok {
+		var op Operation
+		var data operation
+		op, data, err = b.performOperation(ctx, client, instanceID, planContext, details.RawContext, oldPlan, name)
 
 		return domain.UpdateServiceSpec{
-			IsAsync:       false, // feature spec: "[the broker] will not maintain ANY state of the list of users in an Atlas project", so no "in progress" indicator
-			OperationData: operationUpdate,
+			IsAsync:       op.Async,
+			OperationData: b.operationData(data),
 			DashboardURL:  b.GetDashboardURL(oldPlan.Project.ID, oldPlan.Cluster.Name),
 		}, err
 	}
 
-	// Fetch the cluster from Atlas. The Atlas API requires an instance size to
-	// be passed during updates (if there are other update to the provider, such
-	// as region). The plan is not included in the OSB call unless it has changed
-	// hence we need to fetch the current value from Atlas.
-	existingCluster, _, err := client.Clusters.Get(ctx, oldPlan.Project.ID, oldPlan.Cluster.Name)
-	if err != nil {
-		return
+	for _, c := range oldPlan.AllClusters() {
+		if isServerless(c) {
+			err = errors.New("serverless instances cannot be updated")
+
+			return
+		}
 	}
 
 	newPlan, err := b.parsePlan(planContext, details.PlanID)
@@ -260,17 +380,90 @@ func (b Broker) Update(ctx context.Context, instanceID string, details domain.Up
 		return
 	}
 
+	// validate the whole new plan before changing anything in Atlas
+	oldClusters := oldPlan.AllClusters()
+	newClusters := newPlan.AllClusters()
+	if len(oldClusters) != len(newClusters) {
+		err = apiresponses.NewFailureResponse(
+			fmt.Errorf("cannot change the number of clusters from %d to %d", len(oldClusters), len(newClusters)),
+			http.StatusBadRequest, "update",
+		)
+
+		return
+	}
+
 	// Atlas doesn't allow for cluster renaming - ignore any changes
-	newPlan.Cluster.Name = existingCluster.Name
+	for i, c := range oldClusters {
+		newClusters[i].Name = c.Name
+	}
 
-	resultingCluster, _, err := client.Clusters.Update(ctx, oldPlan.Project.ID, existingCluster.Name, newPlan.Cluster)
+	err = validatePlan(newPlan)
 	if err != nil {
-		logger.Errorw("Failed to update Atlas cluster", "error", err, "new_cluster", newPlan.Cluster)
+		err = apiresponses.NewFailureResponse(err, http.StatusBadRequest, "update")
 
 		return
 	}
 
-	planEnc, err := encodePlan(*oldPlan)
+	scaling, err := scalingScheduleOf(newPlan)
+	if err != nil {
+		return
+	}
+
+	resultingClusters := make([]*mongodbatlas.Cluster, 0, len(oldClusters))
+	for i, c := range oldClusters {
+		// Fetch the cluster from Atlas. The Atlas API requires an instance size to
+		// be passed during updates (if there are other update to the provider, such
+		// as region). The plan is not included in the OSB call unless it has changed
+		// hence we need to fetch the current value from Atlas.
+		var existingCluster, resultingCluster *mongodbatlas.Cluster
+		existingCluster, _, err = client.Clusters.Get(ctx, oldPlan.Project.ID, c.Name)
+		if err != nil {
+			return
+		}
+
+		// the instance size is owned by the scaling schedule
+		if scaling.manages(existingCluster.Name) && newClusters[i].ProviderSettings != nil && existingCluster.ProviderSettings != nil {
+			newClusters[i].ProviderSettings.InstanceSizeName = existingCluster.ProviderSettings.InstanceSizeName
+		}
+
+		resultingCluster, _, err = client.Clusters.Update(ctx, oldPlan.Project.ID, existingCluster.Name, withoutPendingEncryption(oldPlan, newClusters[i]))
+		if err != nil {
+			logger.Errorw("Failed to update Atlas cluster", "error", err, "new_cluster", newClusters[i])
+
+			return
+		}
+
+		// keep the declared provider for AuthorizeCloudProviderAccess
+		if withoutPendingEncryption(oldPlan, newClusters[i]) != newClusters[i] {
+			resultingCluster.EncryptionAtRestProvider = newClusters[i].EncryptionAtRestProvider
+		}
+
+		logger.Infow("Successfully started Atlas cluster update process", "cluster", resultingCluster)
+		resultingClusters = append(resultingClusters, resultingCluster)
+	}
+
+	// other changes are only made once Atlas has accepted the cluster updates
+	err = b.applyProjectConfig(ctx, client, oldPlan.Project.ID, newPlan)
+	if err != nil {
+		return
+	}
+
+	err = b.reconcileAlertConfigs(ctx, client, oldPlan.Project.ID, oldPlan.AlertConfigs, newPlan.AlertConfigs)
+	if err != nil {
+		return
+	}
+
+	err = b.reconcileTeams(ctx, client, oldPlan.Project, oldPlan.Teams, newPlan.Teams)
+	if err != nil {
+		return
+	}
+
+	err = b.reconcileCustomDBRoles(ctx, client, oldPlan.Project.ID, oldPlan.CustomDBRoles, newPlan.CustomDBRoles)
+	if err != nil {
+		return
+	}
+
+	err = b.deleteSearchIndexes(ctx, client, oldPlan, newPlan)
 	if err != nil {
 		return
 	}
@@ -280,7 +473,23 @@ func (b Broker) Update(ctx context.Context, instanceID string, details domain.Up
 	oldPlan.Free = newPlan.Free
 	oldPlan.Version = newPlan.Version
 	oldPlan.Settings = newPlan.Settings
-	oldPlan.Cluster = resultingCluster
+	oldPlan.GlobalClusters = newPlan.GlobalClusters
+	oldPlan.BackupPolicy = newPlan.BackupPolicy
+	oldPlan.ProcessArgs = newPlan.ProcessArgs
+	oldPlan.SearchIndexes = newPlan.SearchIndexes
+	oldPlan.Teams = newPlan.Teams
+	oldPlan.CustomDBRoles = newPlan.CustomDBRoles
+	oldPlan.MaintenanceWindow = newPlan.MaintenanceWindow
+	oldPlan.Auditing = newPlan.Auditing
+	oldPlan.ProjectSettings = newPlan.ProjectSettings
+	oldPlan.AlertConfigs = newPlan.AlertConfigs
+	oldPlan.Cluster = resultingClusters[0]
+	oldPlan.Clusters = resultingClusters[1:]
+
+	planEnc, err := encodePlan(*oldPlan)
+	if err != nil {
+		return
+	}
 
 	s := domain.GetInstanceDetailsSpec{
 		PlanID:       details.PlanID,
@@ -310,12 +519,16 @@ func (b Broker) Update(ctx context.Context, instanceID string, details domain.Up
 	}
 
 	logger.Infow("Inserted into state", "obj", obj)
-	logger.Infow("Successfully started Atlas cluster update process", "cluster", resultingCluster)
+
+	err = trackSchedules(ctx, state, instanceID, oldPlan)
+	if err != nil {
+		return
+	}
 
 	return domain.UpdateServiceSpec{
 		IsAsync:       true,
-		OperationData: operationUpdate,
-		DashboardURL:  b.GetDashboardURL(oldPlan.Project.ID, resultingCluster.Name),
+		OperationData: b.operationData(newOperation(operationUpdate)),
+		DashboardURL:  b.GetDashboardURL(oldPlan.Project.ID, oldPlan.Cluster.Name),
 	}, nil
 }
 
@@ -336,26 +549,63 @@ func (b Broker) Deprovision(ctx context.Context, instanceID string, details doma
 		return
 	}
 
-	_, err = client.Clusters.Delete(ctx, p.Project.ID, p.Cluster.Name)
-	if err != nil {
-		logger.Errorw("Failed to delete Atlas cluster", "error", err)
-	}
-
-	for _, u := range p.DatabaseUsers {
-		_, err = client.DatabaseUsers.Delete(ctx, u.DatabaseName, p.Project.ID, u.Username)
+	if settingBool(p.Settings, finalSnapshot) {
+		op := newOperation(operationDeprovision)
+		op.Snapshots, err = b.takeFinalSnapshots(ctx, client, instanceID, p)
 		if err != nil {
-			logger.Errorw("failed to delete Database user", "error", err, "username", u.Username)
+			logger.Errorw("Failed to take final snapshots, not deleting the clusters", "error", err)
+
+			return
 		}
+
+		// the clusters will be deleted by LastOperation once the snapshots complete
+		logger.Infow("Started final snapshots before cluster deletion", "snapshots", op.Snapshots)
+
+		return domain.DeprovisionServiceSpec{
+			IsAsync:       true,
+			OperationData: b.operationData(op),
+		}, nil
 	}
 
+	b.deleteClusters(ctx, client, p, false)
+
 	logger.Infow("Successfully started Atlas Cluster & Project deletion process")
 
 	return domain.DeprovisionServiceSpec{
 		IsAsync:       true,
-		OperationData: operationDeprovision,
+		OperationData: b.operationData(newOperation(operationDeprovision)),
 	}, nil
 }
 
+// deleteClusters starts the deletion of all clusters and network peerings and
+// removes the plan's database users.
+// Errors are only logged: LastOperation reports the actual cluster states.
+func (b Broker) deleteClusters(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan, retainBackups bool) {
+	logger := b.funcLogger()
+
+	for _, c := range p.AllClusters() {
+		_, err := clusterService{client}.Delete(ctx, p.Project.ID, c, retainBackups)
+		if err != nil {
+			logger.Errorw("Failed to delete Atlas cluster", "error", err, "cluster", c.Name)
+		}
+	}
+
+	b.startNetworkDeletion(ctx, client, p)
+
+	for _, u := range p.DatabaseUsers {
+		_, err := client.DatabaseUsers.Delete(ctx, u.DatabaseName, p.Project.ID, u.Username)
+		if err != nil {
+			logger.Errorw("failed to delete Database user", "error", err, "username", u.Username)
+		}
+	}
+}
+
+// instanceParameters are the parameters reported by GetInstance.
+type instanceParameters struct {
+	dynamicplans.Plan
+	NextScheduledActions []scheduledAction `json:"nextScheduledActions,omitempty"`
+}
+
 // GetInstance should fetch the stored instance from state storage
 func (b Broker) GetInstance(ctx context.Context, instanceID string) (spec domain.GetInstanceDetailsSpec, err error) {
 	logger := b.funcLogger().With("instanceID", instanceID)
@@ -373,7 +623,11 @@ func (b Broker) GetInstance(ctx context.Context, instanceID string) (spec domain
 		if err != nil {
 			return spec, apiresponses.NewFailureResponse(err, http.StatusInternalServerError, "get-instance")
 		}
-		spec.Parameters = p.SafeCopy()
+
+		spec.Parameters = instanceParameters{
+			Plan:                 p.SafeCopy(),
+			NextScheduledActions: nextScheduledActions(&p, time.Now()),
+		}
 	}
 
 	return spec, nil
@@ -420,17 +674,21 @@ func (b Broker) LastOperation(ctx context.Context, instanceID string, details do
 		return
 	}
 
-	cluster, r, err := client.Clusters.Get(ctx, p.Project.ID, p.Cluster.Name)
+	states, err := clusterService{client}.States(ctx, p.Project.ID, p.AllClusters())
 	if err != nil {
-		if r == nil || r.StatusCode != http.StatusNotFound {
-			err = errors.Wrap(err, "cannot get existing cluster")
-			logger.Errorw("Failed to get existing cluster", "error", err)
+		err = errors.Wrap(err, "cannot get existing cluster")
+		logger.Errorw("Failed to get existing cluster", "error", err)
 
-			return
-		}
+		return
 	}
 
-	logger.Infow("Found existing cluster", "cluster", cluster)
+	logger.Infow("Found existing clusters", "states", states)
+
+	for _, s := range states {
+		if !knownClusterStates[s.State] {
+			logger.Warnw("Unexpected cluster state", "cluster", s.Name, "state", s.State)
+		}
+	}
 
 	// brokerapi will NOT update service state if we return any error, so... we won't?
 	defer func() {
@@ -441,38 +699,97 @@ func (b Broker) LastOperation(ctx context.Context, instanceID string, details do
 		}
 	}()
 
-	switch details.OperationData {
+	op := parseOperation(details.OperationData)
+
+	timeout, err := op.timeout(p)
+	if err != nil {
+		return
+	}
+
+	if op.Custom != "" {
+		return b.operationProgress(ctx, client, instanceID, p, op, timeout)
+	}
+
+	switch op.Name {
 	case operationProvision, operationUpdate:
-		if r.StatusCode == http.StatusNotFound {
+		if name, ok := states.find(clusterNotFound); ok {
 			resp.State = domain.Failed
-			resp.Description = "cluster not found"
+			resp.Description = fmt.Sprintf("cluster %q not found", name)
 
 			return
 		}
 
-		switch cluster.StateName {
-		// Provision has succeeded if the cluster is in state "idle".
-		case "IDLE":
+		// Provision has succeeded if all clusters are in state "idle".
+		if states.all("IDLE") {
+			// paused clusters don't accept configuration changes
+			if !states.paused() {
+				var restarting bool
+				restarting, err = b.applyClusterConfig(ctx, client, p)
+				if err != nil {
+					return
+				}
+
+				if restarting {
+					b.pollHints.set(instanceID, pollIntervalDefault)
+					resp = b.inProgress(op, timeout, p, "applying processArgs")
+
+					break
+				}
+			}
+
+			var status string
+			var pending bool
+			status, pending, err = b.configProgress(ctx, client, p)
+			if err != nil {
+				return
+			}
+
+			if pending {
+				b.pollHints.set(instanceID, pollIntervalDefault)
+				resp = b.inProgress(op, timeout, p, status)
+
+				break
+			}
+
 			resp.State = domain.Succeeded
-		case "CREATING", "UPDATING", "REPAIRING":
-			resp.State = domain.InProgress
-			resp.Description = cluster.StateName
-		default:
-			resp.Description = fmt.Sprintf("unknown cluster state %q", cluster.StateName)
+			resp.Description = status
+
+			break
+		}
+
+		// Atlas may introduce new transitional states, keep polling until the deadline.
+		if _, ok := states.find("CREATING"); ok {
+			b.pollHints.set(instanceID, pollIntervalCreating)
+		} else {
+			b.pollHints.set(instanceID, pollIntervalDefault)
 		}
 
+		resp = b.inProgress(op, timeout, p, states.String())
+
 	case operationDeprovision:
 		switch {
 		// The Atlas API may return a 404 response if a cluster is deleted or it
 		// will return the cluster with a state of "DELETED". Both of these
 		// scenarios indicate that a cluster has been successfully deleted.
-		case r.StatusCode == http.StatusNotFound, cluster.StateName == "DELETED":
-			if r.StatusCode == http.StatusNotFound || cluster.StateName == "DELETED" {
-				resp.State = domain.Succeeded
+		case states.all(clusterNotFound, "DELETED"):
+			// containers can only be deleted once their clusters and peerings are gone
+			if errNet := b.deleteNetwork(ctx, client, p); errNet != nil {
+				logger.Infow("Waiting for network resources to be deleted", "error", errNet)
+				b.pollHints.set(instanceID, pollIntervalDefault)
+				resp = b.inProgress(op, timeout, p, "waiting for network resources to be deleted: "+errNet.Error())
+
+				break
 			}
 
+			resp.State = domain.Succeeded
+
 			var r *mongodbatlas.Response
-			r, err = client.Projects.Delete(ctx, p.Project.ID)
+			if len(op.Snapshots) == 0 {
+				r, err = client.Projects.Delete(ctx, p.Project.ID)
+			} else {
+				// the project has to stay around for the retained snapshots to remain accessible
+				logger.Infow("Keeping Atlas project with the final snapshots", "projectID", p.Project.ID, "snapshots", op.Snapshots)
+			}
 			if err != nil {
 				err = errors.Wrap(err, "cannot delete Atlas project")
 				logger.Errorw(
@@ -504,11 +821,20 @@ func (b Broker) LastOperation(ctx context.Context, instanceID string, details do
 				break
 			}
 
-		case cluster.StateName == "DELETING":
-			resp.State = domain.InProgress
+			// only exists if a custom operation has been run
+			_ = state.DeleteOne(ctx, operationRecordName(instanceID))
+			// only exists for instances provisioned with a recorded context
+			_ = state.DeleteOne(ctx, platformContextName(instanceID))
+			// only exists for instances which have had a schedule
+			_ = state.DeleteOne(ctx, scheduleRecordName(instanceID))
+
+		case len(op.Snapshots) > 0 && states.has("IDLE"):
+			b.pollHints.set(instanceID, pollIntervalDefault)
+			resp, err = b.finalSnapshotProgress(ctx, client, instanceID, op, timeout, p)
 
 		default:
-			resp.Description = fmt.Sprintf("unknown cluster state %q", cluster.StateName)
+			b.pollHints.set(instanceID, pollIntervalDefault)
+			resp = b.inProgress(op, timeout, p, states.String())
 		}
 
 	default:
@@ -517,3 +843,22 @@ func (b Broker) LastOperation(ctx context.Context, instanceID string, details do
 
 	return resp, err
 }
+
+// inProgress reports a running operation, or fails it once it has exceeded
+// its deadline so that a stuck cluster does not keep the instance busy forever.
+func (b Broker) inProgress(op operation, timeout time.Duration, p *dynamicplans.Plan, status string) domain.LastOperation {
+	if op.expired(timeout) {
+		return domain.LastOperation{
+			State: domain.Failed,
+			Description: fmt.Sprintf(
+				"%s did not complete within %s (cluster status: %s), check the Atlas activity feed for details: %s",
+				op.Name, timeout, status, b.GetActivityFeedURL(p.Project.ID),
+			),
+		}
+	}
+
+	return domain.LastOperation{
+		State:       domain.InProgress,
+		Description: status,
+	}
+}
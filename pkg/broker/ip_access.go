@@ -0,0 +1,199 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func init() {
+	entry := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ipAddress":        map[string]interface{}{"type": "string"},
+			"cidrBlock":        map[string]interface{}{"type": "string"},
+			"awsSecurityGroup": map[string]interface{}{"type": "string", "description": "Requires network peering with the security group's VPC."},
+			"comment":          map[string]interface{}{"type": "string"},
+			"deleteAfterDate":  map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	}
+
+	RegisterOperation(Operation{
+		Name:        "AddIPAccess",
+		Description: "Adds entries to the project's IP access list, replacing existing entries for the same addresses.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"entries": map[string]interface{}{"type": "array", "items": entry, "minItems": 1},
+			},
+			"required": []string{"entries"},
+		},
+		Async:   true,
+		Handler: addIPAccess,
+	})
+
+	RegisterOperation(Operation{
+		Name:        "RemoveIPAccess",
+		Description: "Removes entries from the project's IP access list.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"entries": map[string]interface{}{"type": "array", "items": entry, "minItems": 1},
+			},
+			"required": []string{"entries"},
+		},
+		Async:   true,
+		Handler: removeIPAccess,
+	})
+}
+
+type ipAccessParams struct {
+	Entries []*mongodbatlas.ProjectIPAccessList `json:"entries"`
+}
+
+func (r OperationRequest) ipAccessEntries() ([]*mongodbatlas.ProjectIPAccessList, error) {
+	params := ipAccessParams{}
+
+	err := r.DecodeParams(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range params.Entries {
+		err = validateIPAccessEntry(e)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid entries[%d]", i)
+		}
+	}
+
+	return params.Entries, nil
+}
+
+func validateIPAccessEntry(e *mongodbatlas.ProjectIPAccessList) error {
+	set := 0
+	for _, v := range []string{e.IPAddress, e.CIDRBlock, e.AwsSecurityGroup} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return errors.New("exactly one of ipAddress, cidrBlock and awsSecurityGroup must be set")
+	}
+
+	if e.IPAddress != "" && net.ParseIP(e.IPAddress) == nil {
+		return fmt.Errorf("%q is not an IP address", e.IPAddress)
+	}
+
+	if e.CIDRBlock != "" {
+		if _, _, err := net.ParseCIDR(e.CIDRBlock); err != nil {
+			return err
+		}
+	}
+
+	if e.DeleteAfterDate != "" {
+		if _, err := time.Parse(time.RFC3339, e.DeleteAfterDate); err != nil {
+			return errors.Wrap(err, "deleteAfterDate must be an RFC 3339 timestamp")
+		}
+	}
+
+	return nil
+}
+
+// ipAccessKey identifies an access list entry, as used in the Atlas API paths.
+func ipAccessKey(e *mongodbatlas.ProjectIPAccessList) string {
+	switch {
+	case e.AwsSecurityGroup != "":
+		return e.AwsSecurityGroup
+	case e.IPAddress != "":
+		return e.IPAddress
+	default:
+		return e.CIDRBlock
+	}
+}
+
+func addIPAccess(ctx context.Context, req OperationRequest) error {
+	entries, err := req.ipAccessEntries()
+	if err != nil {
+		return err
+	}
+
+	p := req.Plan
+
+	_, _, err = req.Client.ProjectIPAccessList.Create(ctx, p.Project.ID, entries)
+	if err != nil {
+		return errors.Wrap(err, "cannot create IP Access List entries")
+	}
+
+	for _, e := range entries {
+		replaced := false
+		for i, old := range p.IPAccessLists {
+			if ipAccessKey(old) == ipAccessKey(e) {
+				p.IPAccessLists[i] = e
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			p.IPAccessLists = append(p.IPAccessLists, e)
+		}
+	}
+
+	return req.SavePlan(ctx)
+}
+
+func removeIPAccess(ctx context.Context, req OperationRequest) error {
+	entries, err := req.ipAccessEntries()
+	if err != nil {
+		return err
+	}
+
+	p := req.Plan
+	removed := map[string]bool{}
+
+	for _, e := range entries {
+		key := ipAccessKey(e)
+
+		r, errDel := req.Client.ProjectIPAccessList.Delete(ctx, p.Project.ID, key)
+		if errDel != nil && (r == nil || r.StatusCode != http.StatusNotFound) {
+			// still store the entries removed so far
+			err = errors.Wrapf(errDel, "cannot delete IP Access List entry %q", key)
+
+			break
+		}
+
+		removed[key] = true
+	}
+
+	kept := p.IPAccessLists[:0]
+	for _, e := range p.IPAccessLists {
+		if !removed[ipAccessKey(e)] {
+			kept = append(kept, e)
+		}
+	}
+
+	p.IPAccessLists = kept
+
+	if errSave := req.SavePlan(ctx); errSave != nil {
+		return errSave
+	}
+
+	return err
+}
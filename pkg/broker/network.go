@@ -0,0 +1,341 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Connection types which can be requested with the "connectionType" bind parameter.
+const (
+	connectionStandard        = "standard"
+	connectionPrivate         = "private"
+	connectionPrivateEndpoint = "privateEndpoint"
+)
+
+const networkFailed = "FAILED"
+
+// networkReadyStates are the peering and private endpoint states in which the
+// broker has done its part. PENDING_ACCEPTANCE and WAITING_FOR_USER require
+// the user to act on their side of the connection.
+var networkReadyStates = map[string]bool{
+	"AVAILABLE":          true,
+	"PENDING_ACCEPTANCE": true,
+	"WAITING_FOR_USER":   true,
+}
+
+func validateNetwork(p *dynamicplans.Plan) error {
+	for i, n := range p.NetworkPeering {
+		if n.Container == nil || n.Peer == nil {
+			return fmt.Errorf(".networkPeering[%d] must have both container and peer", i)
+		}
+
+		if n.Container.ProviderName == "" || n.Container.AtlasCIDRBlock == "" {
+			return fmt.Errorf(".networkPeering[%d].container.providerName and .atlasCidrBlock must not be empty", i)
+		}
+
+		if n.Peer.ProviderName != "" && n.Peer.ProviderName != n.Container.ProviderName {
+			return fmt.Errorf(".networkPeering[%d].peer.providerName must match the container's %q", i, n.Container.ProviderName)
+		}
+	}
+
+	for i, e := range p.PrivateEndpoints {
+		if e.ProviderName != "AWS" {
+			return fmt.Errorf(".privateEndpoints[%d].providerName must be AWS, got %q", i, e.ProviderName)
+		}
+
+		if e.Region == "" {
+			return fmt.Errorf(".privateEndpoints[%d].region must not be empty", i)
+		}
+	}
+
+	return nil
+}
+
+// createNetwork creates the network containers, peering connections and
+// private endpoint services of a plan and records their IDs in the plan.
+// Containers have to exist before any cluster is created in their region.
+func (b Broker) createNetwork(ctx context.Context, client *mongodbatlas.Client, groupID string, p *dynamicplans.Plan) error {
+	logger := b.funcLogger()
+
+	for _, n := range p.NetworkPeering {
+		container, _, err := client.Containers.Create(ctx, groupID, n.Container)
+		if err != nil {
+			return errors.Wrap(err, "cannot create Network Peering Container")
+		}
+
+		n.Container.ID = container.ID
+		n.Peer.ContainerID = container.ID
+		if n.Peer.ProviderName == "" {
+			n.Peer.ProviderName = n.Container.ProviderName
+		}
+
+		peer, _, err := client.Peers.Create(ctx, groupID, n.Peer)
+		if err != nil {
+			return errors.Wrap(err, "cannot create Network Peering Connection")
+		}
+
+		n.Peer.ID = peer.ID
+		logger.Infow("Created network peering", "containerID", container.ID, "peerID", peer.ID)
+	}
+
+	for _, e := range p.PrivateEndpoints {
+		// interface endpoints can only be added once the service is ready
+		request := &mongodbatlas.PrivateEndpointConnection{
+			ProviderName: e.ProviderName,
+			Region:       e.Region,
+		}
+
+		endpoint, _, err := client.PrivateEndpoints.Create(ctx, groupID, request)
+		if err != nil {
+			return errors.Wrap(err, "cannot create Private Endpoint Service")
+		}
+
+		e.ID = endpoint.ID
+		logger.Infow("Created private endpoint service", "privateLinkID", endpoint.ID)
+	}
+
+	return nil
+}
+
+func peerStatus(p *mongodbatlas.Peer) string {
+	// AWS reports statusName, Azure and GCP report status
+	if p.StatusName != "" {
+		return p.StatusName
+	}
+
+	return p.Status
+}
+
+// networkProgress checks the peering connections and private endpoint services
+// of a plan and adds the declared interface endpoints once their service is
+// ready. It returns a description of the states and whether any of them is
+// still being set up by Atlas.
+func (b Broker) networkProgress(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) (string, bool, error) {
+	logger := b.funcLogger()
+
+	statuses := []string{}
+	pending := false
+
+	// plans for an existing project don't create network resources, so
+	// entries without an ID are not managed by the broker
+	for _, n := range p.NetworkPeering {
+		if n.Peer.ID == "" {
+			continue
+		}
+
+		peer, _, err := client.Peers.Get(ctx, p.Project.ID, n.Peer.ID)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "cannot get network peering %s", n.Peer.ID)
+		}
+
+		status := peerStatus(peer)
+		if status == networkFailed {
+			return "", false, fmt.Errorf("network peering %s failed: %s %s", peer.ID, peer.ErrorStateName, peer.ErrorMessage)
+		}
+
+		pending = pending || !networkReadyStates[status]
+		statuses = append(statuses, fmt.Sprintf("network peering %s: %s", peer.ID, status))
+	}
+
+	for _, e := range p.PrivateEndpoints {
+		if e.ID == "" {
+			continue
+		}
+
+		endpoint, _, err := client.PrivateEndpoints.Get(ctx, p.Project.ID, e.ID)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "cannot get private endpoint service %s", e.ID)
+		}
+
+		if endpoint.Status == networkFailed {
+			return "", false, fmt.Errorf("private endpoint service %s failed: %s", endpoint.ID, endpoint.ErrorMessage)
+		}
+
+		statuses = append(statuses, fmt.Sprintf("private endpoint service %s: %s", endpoint.ID, endpoint.Status))
+		if !networkReadyStates[endpoint.Status] {
+			pending = true
+
+			continue
+		}
+
+		for _, id := range e.InterfaceEndpoints {
+			if !hasString(endpoint.InterfaceEndpoints, id) {
+				_, _, err = client.PrivateEndpoints.AddOneInterfaceEndpoint(ctx, p.Project.ID, endpoint.ID, id)
+				if err != nil {
+					return "", false, errors.Wrapf(err, "cannot add interface endpoint %s to %s", id, endpoint.ID)
+				}
+
+				logger.Infow("Added interface endpoint", "privateLinkID", endpoint.ID, "interfaceEndpointID", id)
+			}
+
+			ie, _, err := client.PrivateEndpoints.GetOneInterfaceEndpoint(ctx, p.Project.ID, endpoint.ID, id)
+			if err != nil {
+				return "", false, errors.Wrapf(err, "cannot get interface endpoint %s", id)
+			}
+
+			if ie.ConnectionStatus == "REJECTED" {
+				return "", false, fmt.Errorf("interface endpoint %s was rejected: %s", id, ie.ErrorMessage)
+			}
+
+			statuses = append(statuses, fmt.Sprintf("interface endpoint %s: %s", id, ie.ConnectionStatus))
+		}
+	}
+
+	return strings.Join(statuses, ", "), pending, nil
+}
+
+// startNetworkDeletion deletes the peering connections and interface
+// endpoints, which have to be gone before their containers and private
+// endpoint services can be deleted. Errors are only logged.
+func (b Broker) startNetworkDeletion(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) {
+	logger := b.funcLogger()
+
+	for _, n := range p.NetworkPeering {
+		if n.Peer.ID == "" {
+			continue
+		}
+
+		_, err := client.Peers.Delete(ctx, p.Project.ID, n.Peer.ID)
+		if err != nil {
+			logger.Errorw("Failed to delete network peering", "error", err, "peerID", n.Peer.ID)
+		}
+	}
+
+	for _, e := range p.PrivateEndpoints {
+		if e.ID == "" {
+			continue
+		}
+
+		for _, id := range e.InterfaceEndpoints {
+			_, err := client.PrivateEndpoints.DeleteOneInterfaceEndpoint(ctx, p.Project.ID, e.ID, id)
+			if err != nil {
+				logger.Errorw("Failed to delete interface endpoint", "error", err, "privateLinkID", e.ID, "interfaceEndpointID", id)
+			}
+		}
+	}
+}
+
+// deleteNetwork deletes the private endpoint services and network containers.
+// Atlas refuses to while they are still in use, so LastOperation retries it
+// until it succeeds.
+func (b Broker) deleteNetwork(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) error {
+	for _, e := range p.PrivateEndpoints {
+		if e.ID == "" {
+			continue
+		}
+
+		r, err := client.PrivateEndpoints.Delete(ctx, p.Project.ID, e.ID)
+		if err != nil && (r == nil || r.StatusCode != http.StatusNotFound) {
+			return errors.Wrapf(err, "cannot delete private endpoint service %s", e.ID)
+		}
+	}
+
+	for _, n := range p.NetworkPeering {
+		if n.Container.ID == "" {
+			continue
+		}
+
+		r, err := client.Containers.Delete(ctx, p.Project.ID, n.Container.ID)
+		if err != nil && (r == nil || r.StatusCode != http.StatusNotFound) {
+			return errors.Wrapf(err, "cannot delete network container %s", n.Container.ID)
+		}
+	}
+
+	return nil
+}
+
+// connectionString picks the connection string of the requested type.
+func connectionString(cs *mongodbatlas.ConnectionStrings, connectionType string) (string, error) {
+	if cs == nil {
+		return "", errors.New("cluster has no connection strings yet")
+	}
+
+	switch connectionType {
+	case "", connectionStandard:
+		return cs.StandardSrv, nil
+
+	case connectionPrivate:
+		if cs.PrivateSrv == "" {
+			return "", errors.New("cluster has no private connection string, network peering may not be available yet")
+		}
+
+		return cs.PrivateSrv, nil
+
+	case connectionPrivateEndpoint:
+		if len(cs.AwsPrivateLinkSrv) == 0 {
+			return "", errors.New("cluster has no private endpoint connection string, the interface endpoint may not be available yet")
+		}
+
+		// there is one connection string per interface endpoint, pick a stable one
+		return cs.AwsPrivateLinkSrv[firstKey(cs.AwsPrivateLinkSrv)], nil
+
+	default:
+		return "", fmt.Errorf("unknown connectionType %q, must be one of %s, %s, %s", connectionType, connectionStandard, connectionPrivate, connectionPrivateEndpoint)
+	}
+}
+
+// nonSRVConnectionString picks the connection string of the requested type
+// which lists all hosts, or returns "" if Atlas doesn't provide one.
+func nonSRVConnectionString(cs *mongodbatlas.ConnectionStrings, connectionType string) string {
+	if cs == nil {
+		return ""
+	}
+
+	switch connectionType {
+	case "", connectionStandard:
+		return cs.Standard
+	case connectionPrivate:
+		return cs.Private
+	case connectionPrivateEndpoint:
+		// the same interface endpoint as for the SRV connection string
+		return cs.AwsPrivateLink[firstKey(cs.AwsPrivateLinkSrv)]
+	}
+
+	return ""
+}
+
+// firstKey returns the smallest key of m, or "" if it is empty.
+func firstKey(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
+}
+
+func hasString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+)
+
+const (
+	// operationHeartbeatInterval is how often a running operation refreshes
+	// the heartbeat of its record.
+	operationHeartbeatInterval = time.Minute
+
+	// operationHeartbeatTimeout is how long a record may go without a
+	// heartbeat before its operation is considered lost.
+	operationHeartbeatTimeout = 5 * operationHeartbeatInterval
+)
+
+// operationRecord is kept in the state storage while an async custom
+// operation runs in the background, and holds its result once it is done.
+// The platform serializes operations per instance, so there is one record
+// per instance, replaced by every new operation.
+type operationRecord struct {
+	InstanceID  string                    `json:"instanceId"`
+	OperationID string                    `json:"operationId"`
+	Name        string                    `json:"name"`
+	State       domain.LastOperationState `json:"state"`
+	Error       string                    `json:"error,omitempty"`
+	Data        map[string]string         `json:"data,omitempty"`
+	StartedAt   time.Time                 `json:"startedAt"`
+	HeartbeatAt time.Time                 `json:"heartbeatAt,omitempty"`
+	FinishedAt  time.Time                 `json:"finishedAt,omitempty"`
+}
+
+// lost reports whether the broker running the operation has stopped, e.g.
+// because it was restarted, without recording a result.
+func (r *operationRecord) lost(now time.Time) bool {
+	if r.State != domain.InProgress {
+		return false
+	}
+
+	last := r.HeartbeatAt
+	if last.IsZero() {
+		last = r.StartedAt
+	}
+
+	return now.Sub(last) > operationHeartbeatTimeout
+}
+
+// newOperationID returns a random ID matching an operation with its record.
+func newOperationID() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot read random bytes")
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func operationRecordName(instanceID string) string {
+	return "operation-" + instanceID
+}
+
+func (b *Broker) putOperationRecord(ctx context.Context, orgID string, record operationRecord) error {
+	state, err := b.getState(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	name := operationRecordName(record.InstanceID)
+
+	// the record of the previous operation may not exist
+	_ = state.DeleteOne(ctx, name)
+
+	_, err = state.PutValue(ctx, name, record)
+
+	return errors.Wrap(err, "cannot store operation record")
+}
+
+func (b *Broker) getOperationRecord(ctx context.Context, orgID string, instanceID string) (*operationRecord, error) {
+	state, err := b.getState(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &operationRecord{}
+	err = state.FindValue(ctx, operationRecordName(instanceID), record)
+
+	return record, errors.Wrap(err, "cannot get operation record")
+}
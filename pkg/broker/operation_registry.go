@@ -0,0 +1,404 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Operation is a custom operation, performed by passing {"op": Name, ...} as
+// update parameters to an instance.
+type Operation struct {
+	Name        string
+	Description string
+
+	// Parameters is the JSON Schema (draft-04) of the operation parameters
+	// besides "op". It is advertised in the catalog as part of the plans'
+	// update schema, and its "required" properties are checked before the
+	// handler is called.
+	Parameters map[string]interface{}
+
+	// Async operations run in the background. Their result is kept in the
+	// state storage and reported by LastOperation.
+	Async bool
+
+	Handler OperationHandler
+
+	// Progress is optional and requires Async. If set, LastOperation calls it
+	// once the handler has succeeded, to track the Atlas jobs it started.
+	Progress OperationProgress
+}
+
+// OperationRequest is passed to the handler of a custom operation.
+type OperationRequest struct {
+	InstanceID string
+	Client     *mongodbatlas.Client
+	Plan       *dynamicplans.Plan
+
+	// Params holds the raw update parameters, including "op".
+	Params json.RawMessage
+
+	// PlatformContext holds the raw context of the update request, which
+	// identifies the requesting org and space or namespace.
+	PlatformContext json.RawMessage
+
+	// Data is stored in the operation record of async operations. Handlers
+	// can record what Progress needs to track the operation, e.g. Atlas job IDs.
+	Data map[string]string
+
+	broker *Broker
+}
+
+// OperationHandler performs a custom operation.
+type OperationHandler func(ctx context.Context, req OperationRequest) error
+
+// OperationProgress reports the state of an async custom operation. An
+// in-progress operation is failed by LastOperation once it exceeds the plan's
+// update timeout.
+type OperationProgress func(ctx context.Context, req OperationRequest) (domain.LastOperation, error)
+
+var (
+	operationsMu sync.RWMutex
+	operations   = map[string]Operation{}
+)
+
+// RegisterOperation makes a custom operation available to all instances. It
+// is meant to be called from init functions, as the catalog is built from
+// the operations registered when the broker is created. It panics if the
+// operation is invalid or registered twice.
+func RegisterOperation(op Operation) {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+
+	if op.Name == "" || op.Handler == nil {
+		panic("broker: RegisterOperation requires a name and a handler")
+	}
+
+	if op.Progress != nil && !op.Async {
+		panic("broker: RegisterOperation requires Async for operation " + op.Name + " with Progress")
+	}
+
+	if _, dup := operations[op.Name]; dup {
+		panic("broker: RegisterOperation called twice for operation " + op.Name)
+	}
+
+	operations[op.Name] = op
+}
+
+func lookupOperation(name string) (Operation, bool) {
+	operationsMu.RLock()
+	defer operationsMu.RUnlock()
+
+	op, ok := operations[name]
+
+	return op, ok
+}
+
+// registeredOperations returns all operations sorted by name.
+func registeredOperations() []Operation {
+	operationsMu.RLock()
+	defer operationsMu.RUnlock()
+
+	ops := make([]Operation, 0, len(operations))
+	for _, op := range operations {
+		ops = append(ops, op)
+	}
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Name < ops[j].Name })
+
+	return ops
+}
+
+// schema returns the operation's parameter schema including the "op" property.
+func (o Operation) schema() map[string]interface{} {
+	properties := map[string]interface{}{}
+	if p, ok := o.Parameters["properties"].(map[string]interface{}); ok {
+		for k, v := range p {
+			properties[k] = v
+		}
+	}
+
+	properties["op"] = map[string]interface{}{
+		"type": "string",
+		"enum": []string{o.Name},
+	}
+
+	schema := map[string]interface{}{}
+	for k, v := range o.Parameters {
+		schema[k] = v
+	}
+
+	schema["type"] = "object"
+	schema["description"] = o.Description
+	schema["properties"] = properties
+	schema["required"] = append([]string{"op"}, o.required()...)
+
+	return schema
+}
+
+func (o Operation) required() []string {
+	switch r := o.Parameters["required"].(type) {
+	case []string:
+		return r
+	case []interface{}:
+		names := make([]string, 0, len(r))
+		for _, v := range r {
+			if s, ok := v.(string); ok {
+				names = append(names, s)
+			}
+		}
+
+		return names
+	default:
+		return nil
+	}
+}
+
+// updateSchema is the plans' update schema: either a regular plan update
+// without "op", or one of the registered operations.
+func updateSchema() map[string]interface{} {
+	anyOf := []interface{}{
+		map[string]interface{}{
+			"type": "object",
+			"not":  map[string]interface{}{"required": []string{"op"}},
+		},
+	}
+
+	for _, op := range registeredOperations() {
+		anyOf = append(anyOf, op.schema())
+	}
+
+	return map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-04/schema#",
+		"type":    "object",
+		"anyOf":   anyOf,
+	}
+}
+
+// performOperation runs the custom operation requested in the update
+// parameters, or starts it in the background if it is async, and returns the
+// OperationData to report to the platform.
+func (b *Broker) performOperation(ctx context.Context, client *mongodbatlas.Client, instanceID string, planContext dynamicplans.Context, rawContext json.RawMessage, p *dynamicplans.Plan, name string) (Operation, operation, error) {
+	data := newOperation(operationUpdate)
+
+	op, ok := lookupOperation(name)
+	if !ok {
+		return op, data, fmt.Errorf("unknown operation %q", name)
+	}
+
+	for _, r := range op.required() {
+		if _, ok := planContext[r]; !ok {
+			return op, data, fmt.Errorf("operation %q requires parameter %q", name, r)
+		}
+	}
+
+	params, err := json.Marshal(planContext)
+	if err != nil {
+		return op, data, errors.Wrap(err, "cannot encode operation parameters")
+	}
+
+	req := OperationRequest{
+		InstanceID:      instanceID,
+		Client:          client,
+		Plan:            p,
+		Params:          params,
+		PlatformContext: rawContext,
+		Data:            map[string]string{},
+		broker:          b,
+	}
+
+	if !op.Async {
+		b.funcLogger().Infow("Performing custom operation", "instance_id", instanceID, "op", name)
+
+		return op, data, op.Handler(ctx, req)
+	}
+
+	data.Custom = op.Name
+	data.ID, err = newOperationID()
+	if err != nil {
+		return op, data, err
+	}
+
+	timeout, err := data.timeout(p)
+	if err != nil {
+		return op, data, err
+	}
+
+	record := operationRecord{
+		InstanceID:  instanceID,
+		OperationID: data.ID,
+		Name:        op.Name,
+		State:       domain.InProgress,
+		StartedAt:   data.StartedAt,
+		HeartbeatAt: data.StartedAt,
+	}
+
+	// LastOperation must find the record as soon as the platform starts polling
+	err = b.putOperationRecord(ctx, p.Project.OrgID, record)
+	if err != nil {
+		return op, data, err
+	}
+
+	b.funcLogger().Infow("Starting custom operation", "instance_id", instanceID, "op", name, "operation_id", data.ID)
+
+	// the request context ends with the update request
+	go b.runOperation(op, req, record, timeout)
+
+	return op, data, nil
+}
+
+// runOperation runs an async custom operation and records its result.
+func (b *Broker) runOperation(op Operation, req OperationRequest, record operationRecord, timeout time.Duration) {
+	logger := b.funcLogger().With("instance_id", req.InstanceID, "op", op.Name, "operation_id", record.OperationID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go b.operationHeartbeat(req.Plan.Project.OrgID, record, stop, stopped)
+
+	err := op.Handler(ctx, req)
+
+	// the heartbeat must not overwrite the result
+	close(stop)
+	<-stopped
+
+	record.State = domain.Succeeded
+	record.Data = req.Data
+	record.FinishedAt = time.Now().UTC()
+
+	if err != nil {
+		logger.Errorw("Custom operation failed", "error", err)
+		record.State = domain.Failed
+		record.Error = err.Error()
+	} else {
+		logger.Infow("Custom operation completed")
+	}
+
+	// the handler may have used up the timeout
+	err = b.putOperationRecord(context.Background(), req.Plan.Project.OrgID, record)
+	if err != nil {
+		logger.Errorw("Failed to store operation record", "error", err)
+	}
+}
+
+// operationHeartbeat refreshes the heartbeat of a running operation's record
+// until stop is closed, so that LastOperation can tell a long-running
+// operation from one whose broker has gone away.
+func (b *Broker) operationHeartbeat(orgID string, record operationRecord, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(operationHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		record.HeartbeatAt = time.Now().UTC()
+
+		err := b.putOperationRecord(context.Background(), orgID, record)
+		if err != nil {
+			b.funcLogger().Errorw("Failed to store operation heartbeat", "error", err, "instance_id", record.InstanceID, "operation_id", record.OperationID)
+		}
+	}
+}
+
+// operationProgress reports the state of an async custom operation started by
+// performOperation.
+func (b *Broker) operationProgress(ctx context.Context, client *mongodbatlas.Client, instanceID string, p *dynamicplans.Plan, op operation, timeout time.Duration) (domain.LastOperation, error) {
+	custom, ok := lookupOperation(op.Custom)
+	if !ok {
+		return domain.LastOperation{
+			State:       domain.Failed,
+			Description: fmt.Sprintf("unknown operation %q", op.Custom),
+		}, nil
+	}
+
+	b.pollHints.set(instanceID, pollIntervalDefault)
+
+	record, err := b.getOperationRecord(ctx, p.Project.OrgID, instanceID)
+	if err != nil || record.OperationID != op.ID {
+		// the record is briefly missing while it is replaced with the result
+		b.funcLogger().Infow("Operation record not available", "instance_id", instanceID, "op", op.Custom, "error", err)
+
+		return b.inProgress(op, timeout, p, op.Custom+" is running"), nil
+	}
+
+	switch {
+	case record.State == domain.Failed:
+		return domain.LastOperation{
+			State:       domain.Failed,
+			Description: fmt.Sprintf("%s failed: %s", op.Custom, record.Error),
+		}, nil
+
+	case record.lost(time.Now()):
+		return domain.LastOperation{
+			State: domain.Failed,
+			Description: fmt.Sprintf(
+				"%s was interrupted, the broker running it stopped without a result (last heartbeat at %s), check the instance and run it again",
+				op.Custom, record.HeartbeatAt.Format(time.RFC3339),
+			),
+		}, nil
+
+	case record.State != domain.Succeeded:
+		return b.inProgress(op, timeout, p, op.Custom+" is running"), nil
+
+	case custom.Progress == nil:
+		return domain.LastOperation{
+			State:       domain.Succeeded,
+			Description: op.Custom + " completed",
+		}, nil
+	}
+
+	resp, err := custom.Progress(ctx, OperationRequest{
+		InstanceID: instanceID,
+		Client:     client,
+		Plan:       p,
+		Data:       record.Data,
+		broker:     b,
+	})
+	if err != nil || resp.State != domain.InProgress {
+		return resp, err
+	}
+
+	return b.inProgress(op, timeout, p, resp.Description), nil
+}
+
+// DecodeParams decodes the raw operation parameters into v.
+func (r OperationRequest) DecodeParams(v interface{}) error {
+	return errors.Wrap(json.Unmarshal(r.Params, v), "cannot decode operation parameters")
+}
+
+// SavePlan stores r.Plan as the instance's plan. Handlers changing the plan,
+// e.g. to record created resources, must call it for the change to persist.
+func (r OperationRequest) SavePlan(ctx context.Context) error {
+	return r.broker.savePlan(ctx, r.InstanceID, r.Plan)
+}
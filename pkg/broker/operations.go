@@ -0,0 +1,118 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+)
+
+// Plan settings controlling how long an async operation may stay in progress
+// before LastOperation reports it as failed. Values are Go duration strings.
+const (
+	provisionTimeout   = "provisionTimeout"
+	updateTimeout      = "updateTimeout"
+	deprovisionTimeout = "deprovisionTimeout"
+)
+
+const defaultOperationTimeout = 3 * time.Hour
+
+// Poll intervals suggested to the platform via the Retry-After header.
+const (
+	pollIntervalCreating = 60 * time.Second
+	pollIntervalDefault  = 30 * time.Second
+)
+
+// operation is passed to the platform as OperationData and is sent back to
+// the broker on every LastOperation poll.
+type operation struct {
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"startedAt"`
+
+	// Snapshots maps cluster names to the final snapshots taken before deprovisioning.
+	Snapshots map[string]string `json:"snapshots,omitempty"`
+
+	// Custom and ID identify an async custom operation and its operation record.
+	Custom string `json:"custom,omitempty"`
+	ID     string `json:"id,omitempty"`
+}
+
+func newOperation(name string) operation {
+	return operation{
+		Name:      name,
+		StartedAt: time.Now().UTC(),
+	}
+}
+
+// operationData encodes the operation as OperationData. If that fails it
+// falls back to the plain operation name, which parseOperation understands.
+func (b Broker) operationData(o operation) string {
+	data, err := json.Marshal(o)
+	if err != nil {
+		b.funcLogger().Errorw("Cannot encode operation data", "error", err, "operation", o.Name)
+
+		return o.Name
+	}
+
+	return string(data)
+}
+
+// parseOperation decodes OperationData. Operations started by older broker
+// versions only carry the operation name and have no deadline.
+func parseOperation(data string) operation {
+	o := operation{}
+	if err := json.Unmarshal([]byte(data), &o); err != nil || o.Name == "" {
+		return operation{Name: data}
+	}
+
+	return o
+}
+
+// timeout returns the deadline configured in the plan settings for the operation.
+func (o operation) timeout(p *dynamicplans.Plan) (time.Duration, error) {
+	key := ""
+	switch o.Name {
+	case operationProvision:
+		key = provisionTimeout
+	case operationUpdate:
+		key = updateTimeout
+	case operationDeprovision:
+		key = deprovisionTimeout
+	}
+
+	raw, ok := p.Settings[key].(string)
+	if key == "" || !ok {
+		return defaultOperationTimeout, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s setting %q: %w", key, raw, err)
+	}
+
+	return d, nil
+}
+
+// expired reports whether the operation has been running for longer than d.
+func (o operation) expired(d time.Duration) bool {
+	if o.StartedAt.IsZero() {
+		return false
+	}
+
+	return time.Since(o.StartedAt) > d
+}
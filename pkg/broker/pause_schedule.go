@@ -0,0 +1,127 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Plan setting pausing and resuming the clusters on a weekly schedule.
+const pauseScheduleSetting = "pauseSchedule"
+
+const (
+	actionPause  = "pause"
+	actionResume = "resume"
+)
+
+type pauseScheduleSpec struct {
+	Timezone string   `json:"timezone"`
+	Days     []string `json:"days"`
+	Pause    string   `json:"pause"`
+	Resume   string   `json:"resume"`
+}
+
+// pauseScheduleOf returns the pause schedule in the plan settings, or nil.
+func pauseScheduleOf(p *dynamicplans.Plan) (*schedule, error) {
+	spec := pauseScheduleSpec{
+		Timezone: "UTC",
+	}
+
+	ok, err := decodeSetting(p.Settings, pauseScheduleSetting, &spec)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	if spec.Pause == "" && spec.Resume == "" {
+		return nil, errors.New("pauseSchedule must have a pause or resume time")
+	}
+
+	for _, c := range p.AllClusters() {
+		if isServerless(c) || isSharedTier(c) {
+			return nil, errors.New("pauseSchedule requires dedicated clusters")
+		}
+	}
+
+	s, err := newSchedule(spec.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	for action, at := range map[string]string{actionPause: spec.Pause, actionResume: spec.Resume} {
+		if at == "" {
+			continue
+		}
+
+		w, err := parseWeeklyTime(action, at, spec.Days)
+		if err != nil {
+			return nil, err
+		}
+
+		s.times = append(s.times, w)
+	}
+
+	return &s, nil
+}
+
+// applyPauseSchedule pauses or resumes the clusters if the latest scheduled
+// action has not been applied yet. Clusters resumed by Atlas after 30 days
+// or by hand stay up until the next scheduled pause.
+func (b *Broker) applyPauseSchedule(ctx context.Context, client *mongodbatlas.Client, instanceID string, p *dynamicplans.Plan, s *schedule, applied *scheduleRecord, now time.Time) error {
+	w, at, ok := s.last(now)
+	if !ok {
+		return nil
+	}
+
+	key := pauseScheduleSetting
+	if applied.done(key, at) {
+		return nil
+	}
+
+	states, err := clusterService{client}.States(ctx, p.Project.ID, p.AllClusters())
+	if err != nil {
+		return err
+	}
+
+	paused := w.Action == actionPause
+
+	// no need for an update
+	if (paused && states.allPaused()) || (!paused && !states.paused()) {
+		applied.set(key, at)
+
+		return nil
+	}
+
+	// retried on the next run
+	if !states.all("IDLE") {
+		b.funcLogger().Infow("Postponing scheduled action until all clusters are IDLE", "instance_id", instanceID, "action", w.Action, "states", states.String())
+
+		return nil
+	}
+
+	err = pauseClusters(ctx, client, p, paused)
+	if err != nil {
+		return err
+	}
+
+	applied.set(key, at)
+	b.funcLogger().Infow("Applied scheduled action", "instance_id", instanceID, "action", w.Action, "scheduled_at", at)
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// platformContext identifies where on the platform an instance lives: the
+// Cloud Foundry org and space, or the Kubernetes cluster and namespace.
+type platformContext struct {
+	Platform         string `json:"platform,omitempty"`
+	OrganizationGUID string `json:"organization_guid,omitempty"`
+	SpaceGUID        string `json:"space_guid,omitempty"`
+	ClusterID        string `json:"clusterid,omitempty"`
+	Namespace        string `json:"namespace,omitempty"`
+}
+
+func parsePlatformContext(raw json.RawMessage) (platformContext, error) {
+	c := platformContext{}
+	if len(raw) == 0 {
+		return c, nil
+	}
+
+	err := json.Unmarshal(raw, &c)
+
+	return c, errors.Wrap(err, "cannot parse platform context")
+}
+
+// known reports whether the context identifies a tenant at all.
+func (c platformContext) known() bool {
+	return c.SpaceGUID != "" || c.Namespace != ""
+}
+
+// sameTenant reports whether both contexts identify the same org and space,
+// or the same cluster and namespace.
+func (c platformContext) sameTenant(other platformContext) bool {
+	return c.known() && c == other
+}
+
+func platformContextName(instanceID string) string {
+	return "context-" + instanceID
+}
+
+// putPlatformContext records the platform context an instance was
+// provisioned with.
+func (b Broker) putPlatformContext(ctx context.Context, orgID string, instanceID string, raw json.RawMessage) error {
+	c, err := parsePlatformContext(raw)
+	if err != nil {
+		return err
+	}
+
+	state, err := b.getState(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	_, err = state.PutValue(ctx, platformContextName(instanceID), c)
+
+	return errors.Wrap(err, "cannot store platform context")
+}
+
+// getPlatformContext returns the platform context an instance was
+// provisioned with. Instances provisioned before contexts were recorded
+// have an empty one.
+func (b Broker) getPlatformContext(ctx context.Context, orgID string, instanceID string) (platformContext, error) {
+	c := platformContext{}
+
+	state, err := b.getState(ctx, orgID)
+	if err != nil {
+		return c, err
+	}
+
+	err = state.FindValue(ctx, platformContextName(instanceID), &c)
+	if err != nil {
+		return platformContext{}, nil
+	}
+
+	return c, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+var tlsProtocols = map[string]bool{"TLS1_0": true, "TLS1_1": true, "TLS1_2": true}
+
+func validateProcessArgs(p *dynamicplans.Plan) error {
+	a := p.ProcessArgs
+	if a == nil {
+		return nil
+	}
+
+	for _, c := range p.AllClusters() {
+		if isServerless(c) || isSharedTier(c) {
+			return fmt.Errorf("processArgs are only supported for dedicated clusters, %q is not", c.Name)
+		}
+	}
+
+	if a.MinimumEnabledTLSProtocol != "" && !tlsProtocols[a.MinimumEnabledTLSProtocol] {
+		return fmt.Errorf(".processArgs.minimumEnabledTlsProtocol must be one of TLS1_0, TLS1_1, TLS1_2, got %q", a.MinimumEnabledTLSProtocol)
+	}
+
+	if a.DefaultReadConcern != "" && a.DefaultReadConcern != "local" && a.DefaultReadConcern != "available" {
+		return fmt.Errorf(".processArgs.defaultReadConcern must be local or available, got %q", a.DefaultReadConcern)
+	}
+
+	return nil
+}
+
+func processArgsPath(groupID string, clusterName string) string {
+	return fmt.Sprintf("groups/%s/clusters/%s/processArgs", groupID, url.PathEscape(clusterName))
+}
+
+// applyProcessArgs sets the advanced configuration options of all clusters
+// whose current options differ from the plan, and reports whether it changed
+// any. Options left out of the plan keep their current values, options left
+// out by Atlas aren't compared.
+func (b Broker) applyProcessArgs(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) (bool, error) {
+	if p.ProcessArgs == nil {
+		return false, nil
+	}
+
+	declared, err := toJSONValue(p.ProcessArgs)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot encode processArgs")
+	}
+
+	changed := false
+
+	for _, c := range p.AllClusters() {
+		current, err := getProcessArgs(ctx, client, p.Project.ID, c.Name)
+		if err != nil {
+			return false, err
+		}
+
+		if declaredIn(returnedOnly(declared, current), current) {
+			continue
+		}
+
+		req, err := client.NewRequest(ctx, http.MethodPatch, processArgsPath(p.Project.ID, c.Name), p.ProcessArgs)
+		if err != nil {
+			return false, errors.Wrap(err, "cannot create request")
+		}
+
+		_, err = client.Do(ctx, req, nil)
+		if err != nil {
+			return false, errors.Wrapf(err, "cannot update processArgs of %q", c.Name)
+		}
+
+		b.funcLogger().Infow("Updated processArgs", "cluster", c.Name, "processArgs", p.ProcessArgs)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// returnedOnly drops the declared options which Atlas doesn't return at all,
+// such as failIndexKeyTooLong for MongoDB 4.4 and later. They would never
+// match the current options, so the options would be set again on every poll.
+// Options returned as null are unset and still compared.
+func returnedOnly(declared interface{}, current map[string]interface{}) interface{} {
+	d, ok := declared.(map[string]interface{})
+	if !ok {
+		return declared
+	}
+
+	returned := map[string]interface{}{}
+
+	for k, v := range d {
+		if _, ok := current[k]; ok {
+			returned[k] = v
+		}
+	}
+
+	return returned
+}
+
+// getProcessArgs returns the current options as returned by Atlas, which
+// tells options left out apart from unset ones.
+func getProcessArgs(ctx context.Context, client *mongodbatlas.Client, groupID string, clusterName string) (map[string]interface{}, error) {
+	req, err := client.NewRequest(ctx, http.MethodGet, processArgsPath(groupID, clusterName), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+
+	args := map[string]interface{}{}
+	_, err = client.Do(ctx, req, &args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get processArgs of %q", clusterName)
+	}
+
+	return args, nil
+}
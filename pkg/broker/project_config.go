@@ -0,0 +1,85 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func validateProjectConfig(p *dynamicplans.Plan) error {
+	if w := p.MaintenanceWindow; w != nil {
+		if w.DayOfWeek < 1 || w.DayOfWeek > 7 {
+			return fmt.Errorf(".maintenanceWindow.dayOfWeek must be between 1 (Sunday) and 7 (Saturday), got %d", w.DayOfWeek)
+		}
+
+		if w.HourOfDay != nil && (*w.HourOfDay < 0 || *w.HourOfDay > 23) {
+			return fmt.Errorf(".maintenanceWindow.hourOfDay must be between 0 and 23, got %d", *w.HourOfDay)
+		}
+	}
+
+	if a := p.Auditing; a != nil && a.AuditFilter != "" && !json.Valid([]byte(a.AuditFilter)) {
+		return errors.New(".auditing.auditFilter must be a JSON document")
+	}
+
+	return nil
+}
+
+// applyProjectConfig applies the maintenance window, auditing config and
+// project settings of a plan. Sections which are not in the plan are left
+// as they are in Atlas, so this is used for both provision and update.
+func (b Broker) applyProjectConfig(ctx context.Context, client *mongodbatlas.Client, groupID string, p *dynamicplans.Plan) error {
+	logger := b.funcLogger()
+
+	if p.MaintenanceWindow != nil {
+		_, err := client.MaintenanceWindows.Update(ctx, groupID, p.MaintenanceWindow)
+		if err != nil {
+			return errors.Wrap(err, "cannot update Maintenance Window")
+		}
+
+		logger.Infow("Updated maintenance window", "groupID", groupID, "maintenanceWindow", p.MaintenanceWindow)
+	}
+
+	if p.Auditing != nil {
+		_, _, err := client.Auditing.Configure(ctx, groupID, p.Auditing)
+		if err != nil {
+			return errors.Wrap(err, "cannot configure Auditing")
+		}
+
+		logger.Infow("Configured auditing", "groupID", groupID, "enabled", isTrue(p.Auditing.Enabled))
+	}
+
+	if p.ProjectSettings != nil {
+		req, err := client.NewRequest(ctx, http.MethodPatch, fmt.Sprintf("groups/%s/settings", groupID), p.ProjectSettings)
+		if err != nil {
+			return errors.Wrap(err, "cannot create request")
+		}
+
+		_, err = client.Do(ctx, req, nil)
+		if err != nil {
+			return errors.Wrap(err, "cannot update Project Settings")
+		}
+
+		logger.Infow("Updated project settings", "groupID", groupID, "settings", p.ProjectSettings)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func init() {
+	RegisterOperation(Operation{
+		Name:        "RestoreSnapshot",
+		Description: "Restores a cloud backup snapshot, or a point in time, into a cluster of the instance. The cluster's data is replaced.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"snapshotId": map[string]interface{}{"type": "string"},
+				"pointInTime": map[string]interface{}{
+					"type":        "string",
+					"format":      "date-time",
+					"description": "Requires continuous cloud backup on the source cluster.",
+				},
+				"sourceInstanceId": map[string]interface{}{
+					"type":        "string",
+					"description": "Instance owning the snapshot, defaults to this instance. It must belong to the same Atlas organization and the same org and space.",
+				},
+				"sourceCluster": map[string]interface{}{
+					"type":        "string",
+					"description": "Defaults to the main cluster of the source instance.",
+				},
+				"cluster": map[string]interface{}{
+					"type":        "string",
+					"description": "Cluster to restore into, defaults to the main cluster of this instance.",
+				},
+			},
+			"oneOf": []interface{}{
+				map[string]interface{}{"required": []string{"snapshotId"}},
+				map[string]interface{}{"required": []string{"pointInTime"}},
+			},
+		},
+		Async:    true,
+		Handler:  restoreSnapshot,
+		Progress: restoreSnapshotProgress,
+	})
+}
+
+type restoreSnapshotParams struct {
+	SnapshotID       string `json:"snapshotId"`
+	PointInTime      string `json:"pointInTime"`
+	SourceInstanceID string `json:"sourceInstanceId"`
+	SourceCluster    string `json:"sourceCluster"`
+	Cluster          string `json:"cluster"`
+}
+
+// OperationRequest.Data keys of RestoreSnapshot.
+const (
+	restoreSourceInstance = "sourceInstanceId"
+	restoreSourceGroup    = "sourceGroupId"
+	restoreSourceCluster  = "sourceCluster"
+	restoreTargetCluster  = "cluster"
+	restoreJobID          = "jobId"
+)
+
+func restoreSnapshot(ctx context.Context, req OperationRequest) error {
+	params := restoreSnapshotParams{}
+
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
+
+	if (params.SnapshotID == "") == (params.PointInTime == "") {
+		return errors.New("exactly one of snapshotId and pointInTime must be set")
+	}
+
+	target := req.Plan.ClusterByName(params.Cluster)
+	if target == nil {
+		return fmt.Errorf("cluster %q not found in plan", params.Cluster)
+	}
+
+	client, src, err := req.restoreSource(ctx, params.SourceInstanceID)
+	if err != nil {
+		return err
+	}
+
+	source := src.ClusterByName(params.SourceCluster)
+	if source == nil {
+		return fmt.Errorf("source cluster %q not found in plan", params.SourceCluster)
+	}
+
+	job := &mongodbatlas.CloudProviderSnapshotRestoreJob{
+		SnapshotID:        params.SnapshotID,
+		DeliveryType:      "automated",
+		TargetClusterName: target.Name,
+		TargetGroupID:     req.Plan.Project.ID,
+	}
+
+	if params.PointInTime != "" {
+		t, err := time.Parse(time.RFC3339, params.PointInTime)
+		if err != nil {
+			return errors.Wrap(err, "pointInTime must be an RFC 3339 timestamp")
+		}
+
+		job.DeliveryType = "pointInTime"
+		job.PointInTimeUTCSeconds = t.Unix()
+	}
+
+	path := &mongodbatlas.SnapshotReqPathParameters{
+		GroupID:     src.Project.ID,
+		ClusterName: source.Name,
+	}
+
+	job, _, err = client.CloudProviderSnapshotRestoreJobs.Create(ctx, path, job)
+	if err != nil {
+		return errors.Wrapf(err, "cannot restore %q into %q", source.Name, target.Name)
+	}
+
+	req.Data[restoreSourceInstance] = params.SourceInstanceID
+	req.Data[restoreSourceGroup] = src.Project.ID
+	req.Data[restoreSourceCluster] = source.Name
+	req.Data[restoreTargetCluster] = target.Name
+	req.Data[restoreJobID] = job.ID
+
+	return nil
+}
+
+func restoreSnapshotProgress(ctx context.Context, req OperationRequest) (domain.LastOperation, error) {
+	client := req.Client
+
+	// the source instance has been checked when the restore was started
+	if id := req.Data[restoreSourceInstance]; id != "" && id != req.InstanceID {
+		var err error
+
+		client, _, err = req.broker.getClient(ctx, id, "", nil)
+		if err != nil {
+			return domain.LastOperation{}, errors.Wrapf(err, "cannot find source instance %q", id)
+		}
+	}
+
+	path := &mongodbatlas.SnapshotReqPathParameters{
+		GroupID:     req.Data[restoreSourceGroup],
+		ClusterName: req.Data[restoreSourceCluster],
+		JobID:       req.Data[restoreJobID],
+	}
+
+	job, _, err := client.CloudProviderSnapshotRestoreJobs.Get(ctx, path)
+	if err != nil {
+		return domain.LastOperation{}, errors.Wrapf(err, "cannot get restore job %s", path.JobID)
+	}
+
+	target := req.Data[restoreTargetCluster]
+
+	switch {
+	case job.Cancelled || job.Expired:
+		return domain.LastOperation{
+			State: domain.Failed,
+			Description: fmt.Sprintf(
+				"restore job %s into %q was cancelled or expired, check the Atlas activity feed for details: %s",
+				job.ID, target, req.broker.GetActivityFeedURL(req.Plan.Project.ID),
+			),
+		}, nil
+
+	case job.FinishedAt == "":
+		return domain.LastOperation{
+			State:       domain.InProgress,
+			Description: fmt.Sprintf("restoring %q into %q (job %s)", path.ClusterName, target, job.ID),
+		}, nil
+	}
+
+	// the cluster restarts once the data has been restored
+	c, _, err := req.Client.Clusters.Get(ctx, req.Plan.Project.ID, target)
+	if err != nil {
+		return domain.LastOperation{}, errors.Wrapf(err, "cannot get cluster %q", target)
+	}
+
+	if c.StateName != "IDLE" {
+		return domain.LastOperation{
+			State:       domain.InProgress,
+			Description: fmt.Sprintf("restore job %s finished, cluster %q is %s", job.ID, target, c.StateName),
+		}, nil
+	}
+
+	return domain.LastOperation{
+		State:       domain.Succeeded,
+		Description: fmt.Sprintf("restored %q into %q at %s", path.ClusterName, target, job.FinishedAt),
+	}, nil
+}
+
+// restoreSource returns the client and plan of the instance owning the
+// snapshot, which is the requesting instance unless instanceID is set.
+// Another instance may only be used if it was provisioned in the same org
+// and space, or cluster and namespace, as the request comes from.
+func (r OperationRequest) restoreSource(ctx context.Context, instanceID string) (*mongodbatlas.Client, *dynamicplans.Plan, error) {
+	if instanceID == "" || instanceID == r.InstanceID {
+		return r.Client, r.Plan, nil
+	}
+
+	client, p, err := r.broker.getClient(ctx, instanceID, "", nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot find source instance %q", instanceID)
+	}
+
+	if p.Project.OrgID != r.Plan.Project.OrgID {
+		return nil, nil, fmt.Errorf("source instance %q must belong to the same Atlas organization", instanceID)
+	}
+
+	caller, err := parsePlatformContext(r.PlatformContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := r.broker.getPlatformContext(ctx, p.Project.OrgID, instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !source.sameTenant(caller) {
+		return nil, nil, fmt.Errorf("source instance %q must belong to the same org and space as this instance", instanceID)
+	}
+
+	return client, p, nil
+}
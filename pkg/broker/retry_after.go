@@ -0,0 +1,86 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pollHints keeps the Retry-After value computed by the last LastOperation
+// call for each instance until the response headers are written.
+type pollHints struct {
+	m sync.Map
+}
+
+func (h *pollHints) set(instanceID string, d time.Duration) {
+	h.m.Store(instanceID, d)
+}
+
+func (h *pollHints) pop(instanceID string) (time.Duration, bool) {
+	v, ok := h.m.Load(instanceID)
+	if !ok {
+		return 0, false
+	}
+
+	h.m.Delete(instanceID)
+
+	return v.(time.Duration), true
+}
+
+// retryAfterWriter injects the Retry-After header right before the status
+// line is written, after brokerapi has called LastOperation.
+type retryAfterWriter struct {
+	http.ResponseWriter
+	hints      *pollHints
+	instanceID string
+}
+
+func (w *retryAfterWriter) setHeader() {
+	if d, ok := w.hints.pop(w.instanceID); ok {
+		w.Header().Set("Retry-After", fmt.Sprint(int(d.Seconds())))
+	}
+}
+
+func (w *retryAfterWriter) WriteHeader(statusCode int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *retryAfterWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+
+	return w.ResponseWriter.Write(b)
+}
+
+func retryAfterMiddleware(hints *pollHints) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			instanceID, ok := mux.Vars(r)["instance_id"]
+			if !ok || !strings.HasSuffix(r.URL.Path, "/last_operation") {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			next.ServeHTTP(&retryAfterWriter{ResponseWriter: w, hints: hints, instanceID: instanceID}, r)
+		})
+	}
+}
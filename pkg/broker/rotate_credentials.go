@@ -0,0 +1,311 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+const (
+	// maxGracePeriod is the longest lifetime Atlas allows for temporary database users.
+	maxGracePeriod = 7 * 24 * time.Hour
+
+	defaultGracePeriod = 24 * time.Hour
+)
+
+// rotatedSuffix matches the suffix RotateCredentials adds to successor users.
+var rotatedSuffix = regexp.MustCompile(`-r[0-9]+$`)
+
+func init() {
+	RegisterOperation(Operation{
+		Name:        "RotateCredentials",
+		Description: "Replaces database users with successor users with new passwords. The old users keep working for a grace period.",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"users": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Plan database users to rotate, defaults to all users with a password unless bindingIds is set.",
+				},
+				"bindingIds": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Bindings whose database users to rotate too.",
+				},
+				"gracePeriod": map[string]interface{}{
+					"type":        "string",
+					"description": "How long the old users keep working, as a Go duration of at most 168h. Defaults to 24h, 0 deletes them right away.",
+				},
+			},
+		},
+		Async:   true,
+		Handler: rotateCredentials,
+	})
+}
+
+type rotateCredentialsParams struct {
+	Users       []string `json:"users"`
+	BindingIDs  []string `json:"bindingIds"`
+	GracePeriod string   `json:"gracePeriod"`
+}
+
+func rotateCredentials(ctx context.Context, req OperationRequest) error {
+	params := rotateCredentialsParams{}
+
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
+
+	grace := defaultGracePeriod
+	if params.GracePeriod != "" {
+		grace, err = time.ParseDuration(params.GracePeriod)
+		if err != nil {
+			return errors.Wrap(err, "invalid gracePeriod")
+		}
+
+		if grace < 0 || grace > maxGracePeriod {
+			return fmt.Errorf("gracePeriod must be between 0 and %s", maxGracePeriod)
+		}
+	}
+
+	users := []*mongodbatlas.DatabaseUser{}
+	if len(params.Users) > 0 || len(params.BindingIDs) == 0 {
+		users, err = rotatedUsers(req, params.Users)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	err = rotateUsers(ctx, req, users, now, grace)
+
+	// the successor users already created in Atlas must be stored even if a later user failed
+	if len(users) > 0 {
+		if errSave := req.SavePlan(ctx); errSave != nil {
+			return errors.Wrap(errSave, "cannot store rotated users")
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range params.BindingIDs {
+		err = rotateBindingUser(ctx, req, id, now, grace)
+		if err != nil {
+			return err
+		}
+	}
+
+	req.broker.funcLogger().Infow("Rotated credentials", "instance_id", req.InstanceID, "users", len(users), "bindings", len(params.BindingIDs), "grace_period", grace)
+
+	return nil
+}
+
+// rotateUsers replaces each plan user with a successor with a new password.
+func rotateUsers(ctx context.Context, req OperationRequest, users []*mongodbatlas.DatabaseUser, now time.Time, grace time.Duration) error {
+	for _, u := range users {
+		successor, err := rotateUser(ctx, req, u, baseUsername(u.Username), now, grace)
+		if successor != nil {
+			*u = *successor
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateBindingUser replaces the current database user of a binding with a
+// successor with a new password. The platform keeps the credentials it got
+// on bind, so clients have to be given the new ones within the grace period.
+func rotateBindingUser(ctx context.Context, req OperationRequest, bindingID string, now time.Time, grace time.Duration) error {
+	u, err := bindingUser(ctx, req.Client, req.Plan.Project.ID, bindingID)
+	if err != nil {
+		return err
+	}
+
+	_, err = rotateUser(ctx, req, u, bindingID, now, grace)
+
+	return err
+}
+
+// rotateUser creates a successor of u with a new password and lets u expire
+// after the grace period, so that clients can switch over in the meantime.
+// The new credentials are recorded in the operation data under the name the
+// user was declared with, as the instance parameters redact passwords. The
+// successor is returned as soon as it exists in Atlas.
+func rotateUser(ctx context.Context, req OperationRequest, u *mongodbatlas.DatabaseUser, base string, now time.Time, grace time.Duration) (*mongodbatlas.DatabaseUser, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	successor := *u
+	successor.Username = successorUsername(base, now)
+	successor.Password = password
+
+	_, _, err = req.Client.DatabaseUsers.Create(ctx, req.Plan.Project.ID, &successor)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create successor of database user %q", u.Username)
+	}
+
+	req.Data[base+".username"] = successor.Username
+	req.Data[base+".password"] = successor.Password
+
+	old := *u
+
+	return &successor, expireUser(ctx, req.Client, req.Plan.Project.ID, &old, now, grace)
+}
+
+// expireUser lets Atlas delete a replaced user once the grace period is over,
+// or deletes it right away without one.
+func expireUser(ctx context.Context, client *mongodbatlas.Client, groupID string, u *mongodbatlas.DatabaseUser, now time.Time, grace time.Duration) error {
+	if grace == 0 {
+		_, err := client.DatabaseUsers.Delete(ctx, u.DatabaseName, groupID, u.Username)
+
+		return errors.Wrapf(err, "cannot delete replaced database user %q", u.Username)
+	}
+
+	u.DeleteAfterDate = now.Add(grace).Format(time.RFC3339)
+
+	_, _, err := client.DatabaseUsers.Update(ctx, groupID, u.Username, u)
+
+	return errors.Wrapf(err, "cannot expire replaced database user %q", u.Username)
+}
+
+// successorUsername returns the name of the user replacing the given one,
+// which is the name it was declared with plus a rotation suffix.
+func successorUsername(name string, now time.Time) string {
+	return baseUsername(name) + "-r" + strconv.FormatInt(now.Unix(), 10)
+}
+
+// baseUsername returns the name a rotated user was declared with.
+func baseUsername(name string) string {
+	return rotatedSuffix.ReplaceAllString(name, "")
+}
+
+// bindingUsers returns the database users of a binding, which are the user
+// created on bind and the successors created by RotateCredentials, oldest
+// first.
+func bindingUsers(ctx context.Context, client *mongodbatlas.Client, groupID string, bindingID string) ([]mongodbatlas.DatabaseUser, error) {
+	all, _, err := client.DatabaseUsers.List(ctx, groupID, &mongodbatlas.ListOptions{ItemsPerPage: 500})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list database users")
+	}
+
+	users := []mongodbatlas.DatabaseUser{}
+	for _, u := range all {
+		if baseUsername(u.Username) == bindingID {
+			users = append(users, u)
+		}
+	}
+
+	// the binding ID sorts before its successors, which have the same number of digits
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	return users, nil
+}
+
+// bindingUser returns the current database user of a password binding.
+func bindingUser(ctx context.Context, client *mongodbatlas.Client, groupID string, bindingID string) (*mongodbatlas.DatabaseUser, error) {
+	users, err := bindingUsers(ctx, client, groupID, bindingID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("database user of binding %q not found", bindingID)
+	}
+
+	u := users[len(users)-1]
+	if u.DatabaseName != "admin" {
+		return nil, fmt.Errorf("binding %q doesn't use a password", bindingID)
+	}
+
+	return &u, nil
+}
+
+// deleteRotatedUsers deletes the successors of a binding's user, and returns
+// how many there were.
+func deleteRotatedUsers(ctx context.Context, client *mongodbatlas.Client, groupID string, bindingID string) (int, error) {
+	users, err := bindingUsers(ctx, client, groupID, bindingID)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, u := range users {
+		if u.Username == bindingID {
+			continue
+		}
+
+		r, err := client.DatabaseUsers.Delete(ctx, u.DatabaseName, groupID, u.Username)
+		if err != nil && (r == nil || r.StatusCode != http.StatusNotFound) {
+			return deleted, errors.Wrapf(err, "cannot delete database user %q", u.Username)
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// rotatedUsers returns the plan database users with a password matching
+// names, or all of them if names is empty. Names may be given as declared or
+// as rotated.
+func rotatedUsers(req OperationRequest, names []string) ([]*mongodbatlas.DatabaseUser, error) {
+	byName := map[string]*mongodbatlas.DatabaseUser{}
+	users := []*mongodbatlas.DatabaseUser{}
+
+	for _, u := range req.Plan.DatabaseUsers {
+		if u.Password == "" {
+			continue
+		}
+
+		byName[u.Username] = u
+		byName[baseUsername(u.Username)] = u
+		users = append(users, u)
+	}
+
+	if len(names) == 0 {
+		return users, nil
+	}
+
+	users = make([]*mongodbatlas.DatabaseUser, 0, len(names))
+	for _, n := range names {
+		u, ok := byName[n]
+		if !ok {
+			return nil, fmt.Errorf("database user %q with a password not found in plan", n)
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
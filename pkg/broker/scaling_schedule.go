@@ -0,0 +1,213 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Plan setting scaling the clusters between instance sizes on a weekly schedule.
+const scalingScheduleSetting = "scalingSchedule"
+
+const (
+	actionScale = "scale"
+
+	defaultScalingInterval = time.Hour
+)
+
+type scalingStep struct {
+	At           string   `json:"at"`
+	Days         []string `json:"days"`
+	InstanceSize string   `json:"instanceSize"`
+}
+
+type scalingScheduleSpec struct {
+	Timezone    string        `json:"timezone"`
+	MinInterval string        `json:"minInterval"`
+	Clusters    []string      `json:"clusters"`
+	Steps       []scalingStep `json:"steps"`
+}
+
+type scalingSchedule struct {
+	schedule
+
+	// minInterval is the minimum time between two scale events.
+	minInterval time.Duration
+	clusters    map[string]bool
+}
+
+// scalingScheduleOf returns the scaling schedule in the plan settings, or nil.
+// The actions of the schedule are the instance sizes.
+func scalingScheduleOf(p *dynamicplans.Plan) (*scalingSchedule, error) {
+	spec := scalingScheduleSpec{
+		Timezone: "UTC",
+	}
+
+	ok, err := decodeSetting(p.Settings, scalingScheduleSetting, &spec)
+	if !ok || err != nil {
+		return nil, err
+	}
+
+	if len(spec.Steps) == 0 {
+		return nil, errors.New("scalingSchedule must have steps")
+	}
+
+	s := &scalingSchedule{
+		minInterval: defaultScalingInterval,
+		clusters:    map[string]bool{},
+	}
+
+	s.schedule, err = newSchedule(spec.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.MinInterval != "" {
+		s.minInterval, err = time.ParseDuration(spec.MinInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid scalingSchedule.minInterval")
+		}
+	}
+
+	for i, step := range spec.Steps {
+		if step.InstanceSize == "" || sharedTierSizes[step.InstanceSize] {
+			return nil, fmt.Errorf("scalingSchedule.steps[%d].instanceSize must be a dedicated instance size", i)
+		}
+
+		w, err := parseWeeklyTime(step.InstanceSize, step.At, step.Days)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid scalingSchedule.steps[%d]", i)
+		}
+
+		s.times = append(s.times, w)
+	}
+
+	if len(spec.Clusters) == 0 {
+		for _, c := range p.AllClusters() {
+			spec.Clusters = append(spec.Clusters, c.Name)
+		}
+	}
+
+	for _, name := range spec.Clusters {
+		c := p.ClusterByName(name)
+		if c == nil {
+			return nil, fmt.Errorf("scalingSchedule cluster %q not found in plan", name)
+		}
+
+		if isServerless(c) || isSharedTier(c) {
+			return nil, fmt.Errorf("scalingSchedule cluster %q must be a dedicated cluster", name)
+		}
+
+		if c.AutoScaling != nil && c.AutoScaling.Compute != nil && isTrue(c.AutoScaling.Compute.Enabled) {
+			return nil, fmt.Errorf("scalingSchedule cluster %q must not have compute auto-scaling enabled", name)
+		}
+
+		s.clusters[name] = true
+	}
+
+	return s, nil
+}
+
+// manages reports whether the instance size of the cluster is set by the schedule.
+func (s *scalingSchedule) manages(name string) bool {
+	return s != nil && s.clusters[name]
+}
+
+// applyScalingSchedule scales the clusters to the instance size of the latest
+// scheduled step, unless they have been scaled less than minInterval ago. The
+// step is retried on the next run while any cluster is not IDLE or paused.
+func (b *Broker) applyScalingSchedule(ctx context.Context, client *mongodbatlas.Client, instanceID string, p *dynamicplans.Plan, s *scalingSchedule, applied *scheduleRecord, now time.Time) error {
+	logger := b.funcLogger().With("instance_id", instanceID)
+
+	w, at, ok := s.last(now)
+	if !ok {
+		return nil
+	}
+
+	key := scalingScheduleSetting
+	if applied.done(key, at) {
+		return nil
+	}
+
+	size := w.Action
+	pending := []*mongodbatlas.Cluster{}
+
+	for _, c := range p.AllClusters() {
+		if !s.manages(c.Name) {
+			continue
+		}
+
+		existing, _, err := client.Clusters.Get(ctx, p.Project.ID, c.Name)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get cluster %q", c.Name)
+		}
+
+		if existing.ProviderSettings == nil || existing.ProviderSettings.InstanceSizeName == size {
+			continue
+		}
+
+		if existing.StateName != "IDLE" || isTrue(existing.Paused) {
+			logger.Infow("Postponing scheduled scaling until the cluster is IDLE", "cluster", c.Name, "state", existing.StateName, "paused", isTrue(existing.Paused))
+
+			return nil
+		}
+
+		pending = append(pending, existing)
+	}
+
+	if len(pending) == 0 {
+		applied.set(key, at)
+
+		return nil
+	}
+
+	scaledKey := actionScale
+	if last := applied.get(scaledKey); now.Sub(last) < s.minInterval {
+		logger.Infow("Postponing scheduled scaling", "instanceSize", size, "last_scaled", last, "min_interval", s.minInterval)
+
+		return nil
+	}
+
+	for _, existing := range pending {
+		request := &mongodbatlas.Cluster{
+			ProviderSettings: &mongodbatlas.ProviderSettings{
+				ProviderName:     existing.ProviderSettings.ProviderName,
+				RegionName:       existing.ProviderSettings.RegionName,
+				InstanceSizeName: size,
+			},
+		}
+
+		_, _, err := client.Clusters.Update(ctx, p.Project.ID, existing.Name, request)
+		if err != nil {
+			return errors.Wrapf(err, "cannot scale cluster %q to %s", existing.Name, size)
+		}
+
+		logger.Infow("Started scheduled scaling", "cluster", existing.Name, "from", existing.ProviderSettings.InstanceSizeName, "to", size)
+	}
+
+	applied.set(key, at)
+	applied.set(scaledKey, now)
+
+	// The plan is not stored: it may have been changed by an update since it
+	// was read, and updates take the instance size of the scheduled clusters
+	// from Atlas anyway.
+	return nil
+}
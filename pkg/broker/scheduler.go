@@ -0,0 +1,419 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/mongodb/atlas-osb/pkg/broker/statestorage"
+	"github.com/pivotal-cf/brokerapi/domain"
+	"github.com/pkg/errors"
+)
+
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// weeklyTime is an action repeated at a time of day, on some days of the week.
+type weeklyTime struct {
+	Action string
+	hour   int
+	minute int
+	days   map[time.Weekday]bool
+}
+
+// parseWeeklyTime parses a "15:04" time of day. No days mean every day.
+func parseWeeklyTime(action string, at string, days []string) (weeklyTime, error) {
+	w := weeklyTime{
+		Action: action,
+		days:   map[time.Weekday]bool{},
+	}
+
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return w, fmt.Errorf("time of day %q must have the format HH:MM", at)
+	}
+
+	w.hour, w.minute = t.Hour(), t.Minute()
+
+	if len(days) == 0 {
+		for _, d := range weekdays {
+			w.days[d] = true
+		}
+	}
+
+	for _, d := range days {
+		wd, ok := weekdays[d]
+		if !ok {
+			return w, fmt.Errorf("unknown day %q, must be one of Mon, Tue, Wed, Thu, Fri, Sat, Sun", d)
+		}
+
+		w.days[wd] = true
+	}
+
+	return w, nil
+}
+
+// on returns the occurrence on the day of t, and whether there is one.
+func (w weeklyTime) on(t time.Time) (time.Time, bool) {
+	o := time.Date(t.Year(), t.Month(), t.Day(), w.hour, w.minute, 0, 0, t.Location())
+
+	return o, w.days[o.Weekday()]
+}
+
+// schedule is a set of actions repeated every week in a time zone.
+type schedule struct {
+	loc   *time.Location
+	times []weeklyTime
+}
+
+func newSchedule(timezone string) (schedule, error) {
+	loc, err := time.LoadLocation(timezone)
+
+	return schedule{loc: loc}, errors.Wrapf(err, "invalid timezone %q", timezone)
+}
+
+// last returns the latest action at or before now.
+func (s schedule) last(now time.Time) (weeklyTime, time.Time, bool) {
+	now = now.In(s.loc)
+	latest, at := weeklyTime{}, time.Time{}
+
+	for d := 0; d <= 7; d++ {
+		day := now.AddDate(0, 0, -d)
+		for _, w := range s.times {
+			o, ok := w.on(day)
+			if ok && !o.After(now) && o.After(at) {
+				latest, at = w, o
+			}
+		}
+
+		if !at.IsZero() {
+			return latest, at, true
+		}
+	}
+
+	return latest, at, false
+}
+
+// next returns the earliest action after now.
+func (s schedule) next(now time.Time) (weeklyTime, time.Time, bool) {
+	now = now.In(s.loc)
+	earliest, at := weeklyTime{}, time.Time{}
+
+	for d := 0; d <= 7; d++ {
+		day := now.AddDate(0, 0, d)
+		for _, w := range s.times {
+			o, ok := w.on(day)
+			if ok && o.After(now) && (at.IsZero() || o.Before(at)) {
+				earliest, at = w, o
+			}
+		}
+
+		if !at.IsZero() {
+			return earliest, at, true
+		}
+	}
+
+	return earliest, at, false
+}
+
+// scheduledAction is reported by GetInstance for every schedule of the instance.
+type scheduledAction struct {
+	Schedule     string    `json:"schedule"`
+	Action       string    `json:"action"`
+	InstanceSize string    `json:"instanceSize,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// decodeSetting decodes a structured plan setting into v, and reports
+// whether it is set.
+func decodeSetting(settings map[string]interface{}, key string, v interface{}) (bool, error) {
+	raw, ok := settings[key]
+	if !ok || raw == nil {
+		return false, nil
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return true, errors.Wrapf(err, "cannot encode setting %q", key)
+	}
+
+	return true, errors.Wrapf(json.Unmarshal(b, v), "invalid setting %q", key)
+}
+
+// validateSchedules checks the schedules in the plan settings. They can be
+// set by instance parameters too, which validatePlan checks on provision and
+// update.
+func validateSchedules(p *dynamicplans.Plan) error {
+	if _, err := pauseScheduleOf(p); err != nil {
+		return err
+	}
+
+	_, err := scalingScheduleOf(p)
+
+	return err
+}
+
+// nextScheduledActions returns the next action of every schedule of the plan.
+func nextScheduledActions(p *dynamicplans.Plan, now time.Time) []scheduledAction {
+	actions := []scheduledAction{}
+
+	if s, err := pauseScheduleOf(p); err == nil && s != nil {
+		if w, at, ok := s.next(now); ok {
+			actions = append(actions, scheduledAction{Schedule: pauseScheduleSetting, Action: w.Action, At: at})
+		}
+	}
+
+	if s, err := scalingScheduleOf(p); err == nil && s != nil {
+		if w, at, ok := s.next(now); ok {
+			actions = append(actions, scheduledAction{Schedule: scalingScheduleSetting, Action: actionScale, InstanceSize: w.Action, At: at})
+		}
+	}
+
+	return actions
+}
+
+// scheduleRecord remembers the last action applied by every schedule of an
+// instance, so that an action is only applied once and manual changes made
+// afterwards stick until the next scheduled action. It also keeps the time of
+// the last scale event. It is kept in the state storage, so that restarts
+// don't apply an action twice, and created as soon as the instance has a
+// schedule, so that the scheduler only looks at instances with a record.
+type scheduleRecord struct {
+	InstanceID string               `json:"instanceId"`
+	Applied    map[string]time.Time `json:"applied"`
+
+	changed bool
+}
+
+func scheduleRecordName(instanceID string) string {
+	return "schedule-" + instanceID
+}
+
+func (r *scheduleRecord) get(key string) time.Time {
+	return r.Applied[key]
+}
+
+func (r *scheduleRecord) done(key string, at time.Time) bool {
+	return r.get(key).Equal(at)
+}
+
+func (r *scheduleRecord) set(key string, at time.Time) {
+	if r.Applied == nil {
+		r.Applied = map[string]time.Time{}
+	}
+
+	r.Applied[key] = at
+	r.changed = true
+}
+
+func getScheduleRecord(ctx context.Context, state *statestorage.RealmStateStorage, instanceID string) (*scheduleRecord, error) {
+	r := &scheduleRecord{InstanceID: instanceID}
+	err := state.FindValue(ctx, scheduleRecordName(instanceID), r)
+
+	return r, errors.Wrap(err, "cannot get schedule record")
+}
+
+func putScheduleRecord(ctx context.Context, state *statestorage.RealmStateStorage, r *scheduleRecord) error {
+	name := scheduleRecordName(r.InstanceID)
+
+	// the record doesn't exist before the instance has a schedule
+	_ = state.DeleteOne(ctx, name)
+
+	_, err := state.PutValue(ctx, name, r)
+
+	return errors.Wrap(err, "cannot store schedule record")
+}
+
+// trackSchedules creates the empty schedule record of an instance once its
+// plan has a schedule. The record is kept if the schedules are removed, so
+// that actions already applied aren't applied again if they come back.
+func trackSchedules(ctx context.Context, state *statestorage.RealmStateStorage, instanceID string, p *dynamicplans.Plan) error {
+	pause, _ := pauseScheduleOf(p)
+	scaling, _ := scalingScheduleOf(p)
+	if pause == nil && scaling == nil {
+		return nil
+	}
+
+	values, err := state.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot list state values")
+	}
+
+	for _, v := range values {
+		if v.Name == scheduleRecordName(instanceID) {
+			return nil
+		}
+	}
+
+	return putScheduleRecord(ctx, state, &scheduleRecord{InstanceID: instanceID})
+}
+
+// RunScheduler applies the schedules of all instances every interval, until
+// ctx is done. Actions missed while the broker was down are applied once it
+// is back. A zero interval, the default, disables the scheduler. The applied
+// actions are not locked, so only one broker replica may run the scheduler.
+func (b *Broker) RunScheduler(ctx context.Context, interval time.Duration) {
+	if b.credentials == nil || interval <= 0 {
+		return
+	}
+
+	b.funcLogger().Infow("Starting scheduler", "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for orgID := range b.credentials.Keys() {
+			b.runSchedules(ctx, orgID, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isRecordName reports whether a state storage value is a record rather than an instance.
+func isRecordName(name string) bool {
+	return strings.HasPrefix(name, finalSnapshotName("")) ||
+		strings.HasPrefix(name, operationRecordName("")) ||
+		strings.HasPrefix(name, platformContextName("")) ||
+		strings.HasPrefix(name, scheduleRecordName(""))
+}
+
+func (b *Broker) runSchedules(ctx context.Context, orgID string, now time.Time) {
+	logger := b.funcLogger().With("orgID", orgID)
+
+	state, err := b.getState(ctx, orgID)
+	if err != nil {
+		logger.Errorw("Cannot get state storage for org", "error", err)
+
+		return
+	}
+
+	values, err := state.List(ctx)
+	if err != nil {
+		logger.Errorw("Cannot list instances", "error", err)
+
+		return
+	}
+
+	names := map[string]bool{}
+	for _, v := range values {
+		names[v.Name] = true
+	}
+
+	for _, v := range values {
+		// instances without a schedule record have never had a schedule
+		if isRecordName(v.Name) || !names[scheduleRecordName(v.Name)] {
+			continue
+		}
+
+		val, err := state.Get(ctx, v.ID)
+		if err != nil {
+			logger.Errorw("Cannot get instance", "instance_id", v.Name, "error", err)
+
+			continue
+		}
+
+		spec := domain.GetInstanceDetailsSpec{}
+		if err := json.Unmarshal(val.Value, &spec); err != nil {
+			continue
+		}
+
+		enc, ok := spec.Parameters.(string)
+		if !ok {
+			continue
+		}
+
+		p, err := decodePlan(enc)
+		if err != nil {
+			logger.Errorw("Cannot decode plan", "instance_id", v.Name, "error", err)
+
+			continue
+		}
+
+		// without the record, actions which have been applied already would
+		// be applied again
+		applied, err := getScheduleRecord(ctx, state, v.Name)
+		if err != nil {
+			logger.Errorw("Cannot get applied scheduled actions", "instance_id", v.Name, "error", err)
+
+			continue
+		}
+
+		err = b.applySchedules(ctx, v.Name, &p, applied, now)
+		if err != nil {
+			logger.Errorw("Cannot apply schedules", "instance_id", v.Name, "error", err)
+		}
+
+		// actions applied before an error must be recorded too
+		if applied.changed {
+			if err := putScheduleRecord(ctx, state, applied); err != nil {
+				logger.Errorw("Cannot store applied scheduled actions", "instance_id", v.Name, "error", err)
+			}
+		}
+	}
+}
+
+func (b *Broker) applySchedules(ctx context.Context, instanceID string, p *dynamicplans.Plan, applied *scheduleRecord, now time.Time) error {
+	pause, err := pauseScheduleOf(p)
+	if err != nil {
+		return err
+	}
+
+	scaling, err := scalingScheduleOf(p)
+	if err != nil {
+		return err
+	}
+
+	if pause == nil && scaling == nil {
+		return nil
+	}
+
+	client, err := b.planClient(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	// scale first: clusters scaled down in the evening are paused once the
+	// scaling has completed
+	if scaling != nil {
+		err = b.applyScalingSchedule(ctx, client, instanceID, p, scaling, applied, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pause != nil {
+		return b.applyPauseSchedule(ctx, client, instanceID, p, pause, applied, now)
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// searchIndexState is a search index as listed by Atlas, including its build
+// status which the Go client doesn't expose.
+type searchIndexState struct {
+	mongodbatlas.SearchIndex
+	Status string `json:"status,omitempty"`
+}
+
+func validateSearchIndexes(p *dynamicplans.Plan) error {
+	keys := map[string]bool{}
+
+	for i, s := range p.SearchIndexes {
+		if s.Name == "" || s.Database == "" || s.CollectionName == "" {
+			return fmt.Errorf(".searchIndexes[%d].name, .database and .collectionName must not be empty", i)
+		}
+
+		if s.Mappings == nil {
+			return fmt.Errorf(".searchIndexes[%d].mappings must not be empty", i)
+		}
+
+		c := p.ClusterByName(s.ClusterName)
+		if c == nil {
+			return fmt.Errorf("search index %q refers to unknown cluster %q", s.Name, s.ClusterName)
+		}
+
+		if isServerless(c) || isSharedTier(c) {
+			return fmt.Errorf("search index %q: Atlas Search is only supported for dedicated clusters, %q is not", s.Name, c.Name)
+		}
+
+		key := searchIndexKey(p, s)
+		if keys[key] {
+			return fmt.Errorf("duplicate search index %s", key)
+		}
+		keys[key] = true
+	}
+
+	return nil
+}
+
+func searchIndexKey(p *dynamicplans.Plan, s *dynamicplans.SearchIndex) string {
+	name := s.ClusterName
+	if c := p.ClusterByName(s.ClusterName); c != nil {
+		name = c.Name
+	}
+
+	return fmt.Sprintf("%s/%s.%s/%s", name, s.Database, s.CollectionName, s.Name)
+}
+
+// searchIndexCluster returns the name of the cluster the index is declared on.
+func searchIndexCluster(p *dynamicplans.Plan, s *dynamicplans.SearchIndex) (string, error) {
+	c := p.ClusterByName(s.ClusterName)
+	if c == nil {
+		err := fmt.Errorf("search index %q refers to unknown cluster %q", s.Name, s.ClusterName)
+
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "search-index")
+	}
+
+	return c.Name, nil
+}
+
+func listSearchIndexes(ctx context.Context, client *mongodbatlas.Client, groupID string, clusterName string, database string, collection string) ([]searchIndexState, error) {
+	path := fmt.Sprintf(
+		"groups/%s/clusters/%s/fts/indexes/%s/%s",
+		groupID, url.PathEscape(clusterName), url.PathEscape(database), url.PathEscape(collection),
+	)
+
+	req, err := client.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+
+	indexes := []searchIndexState{}
+	_, err = client.Do(ctx, req, &indexes)
+
+	return indexes, err
+}
+
+// findSearchIndex returns the Atlas state of a declared index, or nil if it doesn't exist.
+func findSearchIndex(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan, s *dynamicplans.SearchIndex) (*searchIndexState, error) {
+	clusterName, err := searchIndexCluster(p, s)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := listSearchIndexes(ctx, client, p.Project.ID, clusterName, s.Database, s.CollectionName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list search indexes of %s", searchIndexKey(p, s))
+	}
+
+	for i := range indexes {
+		if indexes[i].Name == s.Name {
+			return &indexes[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// applySearchIndexes creates the declared search indexes which don't exist
+// yet and updates the ones whose definition has changed. Unchanged indexes
+// are left alone, so that polling doesn't trigger rebuilds.
+func (b Broker) applySearchIndexes(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) error {
+	logger := b.funcLogger()
+
+	for _, s := range p.SearchIndexes {
+		key := searchIndexKey(p, s)
+
+		clusterName, err := searchIndexCluster(p, s)
+		if err != nil {
+			return err
+		}
+
+		existing, err := findSearchIndex(ctx, client, p, s)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			_, _, err = client.Search.CreateIndex(ctx, p.Project.ID, clusterName, s.Index())
+			if err != nil {
+				return errors.Wrapf(err, "cannot create search index %s", key)
+			}
+
+			logger.Infow("Created search index", "index", key)
+
+			continue
+		}
+
+		if !searchIndexChanged(s, &existing.SearchIndex) {
+			continue
+		}
+
+		_, _, err = client.Search.UpdateIndex(ctx, p.Project.ID, clusterName, existing.IndexID, s.Index())
+		if err != nil {
+			return errors.Wrapf(err, "cannot update search index %s", key)
+		}
+
+		logger.Infow("Updated search index", "index", key)
+	}
+
+	return nil
+}
+
+// searchIndexChanged compares the declared definition with the one in Atlas.
+// Only declared fields are compared, as Atlas fills in the defaults.
+func searchIndexChanged(s *dynamicplans.SearchIndex, current *mongodbatlas.SearchIndex) bool {
+	if s.Analyzer != "" && s.Analyzer != current.Analyzer {
+		return true
+	}
+
+	if s.SearchAnalyzer != "" && s.SearchAnalyzer != current.SearchAnalyzer {
+		return true
+	}
+
+	declared, err := toJSONValue(s.Mappings)
+	if err != nil {
+		return true
+	}
+
+	applied, err := toJSONValue(current.Mappings)
+	if err != nil {
+		return true
+	}
+
+	return !declaredIn(declared, applied)
+}
+
+// deleteSearchIndexes deletes the indexes of the old plan which are no longer
+// declared in the new one.
+func (b Broker) deleteSearchIndexes(ctx context.Context, client *mongodbatlas.Client, oldPlan *dynamicplans.Plan, newPlan *dynamicplans.Plan) error {
+	declared := map[string]bool{}
+	for _, s := range newPlan.SearchIndexes {
+		declared[searchIndexKey(newPlan, s)] = true
+	}
+
+	for _, s := range oldPlan.SearchIndexes {
+		key := searchIndexKey(oldPlan, s)
+		if declared[key] {
+			continue
+		}
+
+		existing, err := findSearchIndex(ctx, client, oldPlan, s)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			continue
+		}
+
+		clusterName, err := searchIndexCluster(oldPlan, s)
+		if err != nil {
+			return err
+		}
+
+		_, err = client.Search.DeleteIndex(ctx, oldPlan.Project.ID, clusterName, existing.IndexID)
+		if err != nil {
+			return errors.Wrapf(err, "cannot delete search index %s", key)
+		}
+
+		b.funcLogger().Infow("Deleted search index", "index", key)
+	}
+
+	return nil
+}
+
+// searchIndexProgress reports the build status of the declared search indexes
+// and whether any of them is still being built.
+func (b Broker) searchIndexProgress(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan) (string, bool, error) {
+	statuses := []string{}
+	pending := false
+
+	for _, s := range p.SearchIndexes {
+		key := searchIndexKey(p, s)
+
+		existing, err := findSearchIndex(ctx, client, p, s)
+		if err != nil {
+			return "", false, err
+		}
+
+		if existing == nil {
+			return "", false, fmt.Errorf("search index %s not found", key)
+		}
+
+		if existing.Status == "FAILED" {
+			return "", false, fmt.Errorf("search index %s failed to build", key)
+		}
+
+		if existing.Status != "STEADY" {
+			pending = true
+			statuses = append(statuses, fmt.Sprintf("search index %s: %s", key, existing.Status))
+		}
+	}
+
+	return strings.Join(statuses, ", "), pending, nil
+}
@@ -86,6 +86,13 @@ func (b *Broker) buildPlansForProviderDynamic() []domain.ServicePlan {
 
 	plans := make([]domain.ServicePlan, 0, len(templates))
 
+	// custom operations are passed as update parameters
+	schemas := &domain.ServiceSchemas{
+		Instance: domain.ServiceInstanceSchema{
+			Update: domain.Schema{Parameters: updateSchema()},
+		},
+	}
+
 	for _, template := range templates {
 		raw := new(bytes.Buffer)
 
@@ -105,26 +112,17 @@ func (b *Broker) buildPlansForProviderDynamic() []domain.ServicePlan {
 
 		logger.Infof("Parsed plan: %s", p.SafeCopy())
 
-		if p.Cluster == nil ||
-			p.Cluster.ProviderSettings == nil {
-			if p.Cluster.ProviderSettings.ProviderName == "" {
-				logger.Errorw(
-					"invalid yaml template",
-					"name", template.Name(),
-					"error", ".cluster.providerSettings.providerName must not be empty",
-				)
-
-				continue
-			}
-			if p.Cluster.ProviderSettings.InstanceSizeName == "" {
-				logger.Errorw(
-					"invalid yaml template",
-					"name", template.Name(),
-					"error", ".cluster.providerSettings.instanceSizeName must not be empty",
-				)
-
-				continue
-			}
+		p.NormalizeClusters()
+
+		if err := validatePlan(&p); err != nil {
+			logger.Errorw("invalid yaml template", "name", template.Name(), "error", err)
+
+			continue
+		}
+
+		instanceSize := p.Cluster.ProviderSettings.InstanceSizeName
+		if isServerless(p.Cluster) {
+			instanceSize = providerServerless
 		}
 
 		plan := domain.ServicePlan{
@@ -132,12 +130,13 @@ func (b *Broker) buildPlansForProviderDynamic() []domain.ServicePlan {
 			Name:        p.Name,
 			Description: p.Description,
 			Free:        p.Free,
+			Schemas:     schemas,
 			Metadata: &domain.ServicePlanMetadata{
 				DisplayName: p.Name,
 				Bullets:     []string{p.Description},
 				AdditionalMetadata: map[string]interface{}{
 					"template":     dynamicplans.TemplateContainer{Template: template},
-					"instanceSize": p.Cluster.ProviderSettings.InstanceSizeName,
+					"instanceSize": instanceSize,
 				},
 			},
 		}
@@ -149,6 +148,59 @@ func (b *Broker) buildPlansForProviderDynamic() []domain.ServicePlan {
 	return plans
 }
 
+// validatePlan checks a parsed plan template before it is added to the catalog.
+func validatePlan(p *dynamicplans.Plan) error {
+	if err := validateClusters(p); err != nil {
+		return err
+	}
+
+	if err := validateGlobalClusters(p); err != nil {
+		return err
+	}
+
+	if err := validateCustomDBRoles(p); err != nil {
+		return err
+	}
+
+	if err := validateNetwork(p); err != nil {
+		return err
+	}
+
+	if err := validateEncryption(p); err != nil {
+		return err
+	}
+
+	if err := validateProjectConfig(p); err != nil {
+		return err
+	}
+
+	if err := validateAlertConfigs(p); err != nil {
+		return err
+	}
+
+	if err := validateBackupPolicy(p); err != nil {
+		return err
+	}
+
+	if err := validateProcessArgs(p); err != nil {
+		return err
+	}
+
+	if err := validateSearchIndexes(p); err != nil {
+		return err
+	}
+
+	if err := validateSchedules(p); err != nil {
+		return err
+	}
+
+	if err := validateBindingFormats(p); err != nil {
+		return err
+	}
+
+	return validateTeams(p)
+}
+
 // serviceIDForProvider will generate a globally unique ID for a provider.
 func serviceIDForProvider(providerName string) string {
 	return fmt.Sprintf("%s-service-%s", idPrefix, strings.ToLower(providerName))
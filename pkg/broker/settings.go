@@ -0,0 +1,45 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"fmt"
+)
+
+// Plan settings are decoded from YAML templates and later round-tripped through
+// JSON in the state storage, so numbers can show up with different types.
+
+func settingBool(settings map[string]interface{}, key string) bool {
+	v, ok := settings[key].(bool)
+
+	return ok && v
+}
+
+func settingInt(settings map[string]interface{}, key string, def int) (int, error) {
+	switch v := settings[key].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("setting %q must be a number, got %v (%T)", key, v, v)
+	}
+}
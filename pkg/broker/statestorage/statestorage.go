@@ -181,10 +181,22 @@ func (ss *RealmStateStorage) idByName(ctx context.Context, name string) (id stri
 	return "", fmt.Errorf("value with name %q not found", name)
 }
 
-func (ss *RealmStateStorage) FindOne(ctx context.Context, name string) (spec *domain.GetInstanceDetailsSpec, err error) {
+func (ss *RealmStateStorage) FindOne(ctx context.Context, name string) (*domain.GetInstanceDetailsSpec, error) {
+	spec := &domain.GetInstanceDetailsSpec{}
+
+	err := ss.FindValue(ctx, name, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// FindValue decodes the value stored under the given name into v.
+func (ss *RealmStateStorage) FindValue(ctx context.Context, name string, v interface{}) error {
 	id, err := ss.idByName(ctx, name)
 	if err != nil {
-		return
+		return err
 	}
 
 	val, err := ss.Get(ctx, id)
@@ -194,17 +206,14 @@ func (ss *RealmStateStorage) FindOne(ctx context.Context, name string) (spec *do
 			err = ErrInstanceNotFound
 		}
 
-		return
+		return err
 	}
 
 	if val.Value == nil {
-		return nil, errors.New("val.Value was nil from realm, should never happen")
+		return errors.New("val.Value was nil from realm, should never happen")
 	}
 
-	spec = &domain.GetInstanceDetailsSpec{}
-	err = json.Unmarshal(val.Value, &spec)
-
-	return
+	return json.Unmarshal(val.Value, v)
 }
 
 func (ss *RealmStateStorage) DeleteOne(ctx context.Context, name string) error {
@@ -219,6 +228,11 @@ func (ss *RealmStateStorage) DeleteOne(ctx context.Context, name string) error {
 }
 
 func (ss *RealmStateStorage) Put(ctx context.Context, name string, value *domain.GetInstanceDetailsSpec) (*mongodbrealm.RealmValue, error) {
+	return ss.PutValue(ctx, name, value)
+}
+
+// PutValue stores an arbitrary JSON-serializable value under the given name.
+func (ss *RealmStateStorage) PutValue(ctx context.Context, name string, value interface{}) (*mongodbrealm.RealmValue, error) {
 	vv, err := json.Marshal(value)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot marshal value")
@@ -234,6 +248,13 @@ func (ss *RealmStateStorage) Put(ctx context.Context, name string, value *domain
 	return v, err
 }
 
+// List returns the names and IDs of all stored values, without their contents.
+func (ss *RealmStateStorage) List(ctx context.Context) ([]mongodbrealm.RealmValue, error) {
+	values, _, err := ss.RealmClient.RealmValues.List(ctx, ss.RealmProject.ID, ss.RealmApp.ID, nil)
+
+	return values, err
+}
+
 func (ss *RealmStateStorage) Get(ctx context.Context, key string) (*mongodbrealm.RealmValue, error) {
 	v, _, err := ss.RealmClient.RealmValues.Get(ctx, ss.RealmProject.ID, ss.RealmApp.ID, key)
 
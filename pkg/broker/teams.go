@@ -0,0 +1,242 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func init() {
+	RegisterOperation(Operation{
+		Name:        "AddTeamToProject",
+		Description: "Adds an existing Atlas team of the organization to the project.",
+		Parameters:  projectTeamSchema,
+		Async:       true,
+		Handler:     addTeamToProject,
+	})
+
+	RegisterOperation(Operation{
+		Name:        "RemoveTeamFromProject",
+		Description: "Removes an Atlas team from the project.",
+		Parameters:  projectTeamSchema,
+		Async:       true,
+		Handler:     removeTeamFromProject,
+	})
+}
+
+type projectTeamParams struct {
+	TeamID   string `json:"teamId"`
+	TeamName string `json:"teamName"`
+}
+
+var projectTeamSchema = map[string]interface{}{
+	"properties": map[string]interface{}{
+		"teamId":   map[string]interface{}{"type": "string"},
+		"teamName": map[string]interface{}{"type": "string"},
+	},
+	"oneOf": []interface{}{
+		map[string]interface{}{"required": []string{"teamId"}},
+		map[string]interface{}{"required": []string{"teamName"}},
+	},
+}
+
+func validateTeams(p *dynamicplans.Plan) error {
+	for i, t := range p.Teams {
+		if t.TeamID == "" && t.Name == "" {
+			return fmt.Errorf(".teams[%d] must have either teamId or name", i)
+		}
+
+		if len(t.RoleNames) == 0 {
+			return fmt.Errorf(".teams[%d].roleNames must not be empty", i)
+		}
+	}
+
+	return nil
+}
+
+func resolveTeamID(ctx context.Context, client *mongodbatlas.Client, orgID string, t *dynamicplans.Team) (string, error) {
+	if t.TeamID != "" {
+		return t.TeamID, nil
+	}
+
+	team, _, err := client.Teams.GetOneTeamByName(ctx, orgID, t.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get Atlas team %q", t.Name)
+	}
+
+	return team.ID, nil
+}
+
+func sameRoles(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reconcileTeams assigns the teams of newTeams to the project with their
+// roles and removes the teams which were only in oldTeams. Teams assigned
+// by other means, e.g. the AddTeamToProject operation, are left alone.
+func (b Broker) reconcileTeams(ctx context.Context, client *mongodbatlas.Client, project *mongodbatlas.Project, oldTeams []*dynamicplans.Team, newTeams []*dynamicplans.Team) error {
+	logger := b.funcLogger()
+
+	if len(oldTeams) == 0 && len(newTeams) == 0 {
+		return nil
+	}
+
+	assigned, _, err := client.Projects.GetProjectTeamsAssigned(ctx, project.ID)
+	if err != nil {
+		return errors.Wrap(err, "cannot get teams assigned to Atlas project")
+	}
+
+	current := map[string][]string{}
+	for _, r := range assigned.Results {
+		current[r.TeamID] = r.RoleNames
+	}
+
+	declared := map[string]bool{}
+	add := []*mongodbatlas.ProjectTeam{}
+
+	for _, t := range newTeams {
+		id, err := resolveTeamID(ctx, client, project.OrgID, t)
+		if err != nil {
+			return err
+		}
+
+		declared[id] = true
+
+		roles, ok := current[id]
+		switch {
+		case !ok:
+			add = append(add, &mongodbatlas.ProjectTeam{TeamID: id, RoleNames: t.RoleNames})
+
+		case !sameRoles(roles, t.RoleNames):
+			_, _, err = client.Teams.UpdateTeamRoles(ctx, project.ID, id, &mongodbatlas.TeamUpdateRoles{RoleNames: t.RoleNames})
+			if err != nil {
+				return errors.Wrapf(err, "cannot update roles of Atlas team %s", id)
+			}
+
+			logger.Infow("Updated team roles", "teamID", id, "roles", t.RoleNames)
+		}
+	}
+
+	if len(add) > 0 {
+		_, _, err = client.Projects.AddTeamsToProject(ctx, project.ID, add)
+		if err != nil {
+			return errors.Wrap(err, "cannot add teams to Atlas project")
+		}
+
+		logger.Infow("Added teams to project", "teams", add)
+	}
+
+	for _, t := range oldTeams {
+		id, err := resolveTeamID(ctx, client, project.OrgID, t)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := current[id]; !ok || declared[id] {
+			continue
+		}
+
+		err = removeTeam(ctx, client, project.ID, id)
+		if err != nil {
+			return err
+		}
+
+		logger.Infow("Removed team from project", "teamID", id)
+	}
+
+	return nil
+}
+
+func removeTeam(ctx context.Context, client *mongodbatlas.Client, groupID string, teamID string) error {
+	r, err := client.Teams.RemoveTeamFromProject(ctx, groupID, teamID)
+	if err != nil && (r == nil || r.StatusCode != http.StatusNotFound) {
+		return errors.Wrapf(err, "cannot remove Atlas team %s from project", teamID)
+	}
+
+	return nil
+}
+
+// teamFromParams builds a team from the "teamId" or "teamName" operation parameters.
+func teamFromParams(req OperationRequest) (*dynamicplans.Team, error) {
+	params := projectTeamParams{}
+
+	err := req.DecodeParams(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.TeamID == "" && params.TeamName == "" {
+		return nil, errors.New("either teamId or teamName must be specified")
+	}
+
+	return &dynamicplans.Team{TeamID: params.TeamID, Name: params.TeamName}, nil
+}
+
+func addTeamToProject(ctx context.Context, req OperationRequest) error {
+	t, err := teamFromParams(req)
+	if err != nil {
+		return err
+	}
+
+	t.RoleNames, err = projectRoleNames(req.Plan)
+	if err != nil {
+		return err
+	}
+
+	id, err := resolveTeamID(ctx, req.Client, req.Plan.Project.OrgID, t)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = req.Client.Projects.AddTeamsToProject(ctx, req.Plan.Project.ID, []*mongodbatlas.ProjectTeam{{TeamID: id, RoleNames: t.RoleNames}})
+
+	return errors.Wrap(err, "cannot add Atlas team to Project")
+}
+
+func removeTeamFromProject(ctx context.Context, req OperationRequest) error {
+	t, err := teamFromParams(req)
+	if err != nil {
+		return err
+	}
+
+	id, err := resolveTeamID(ctx, req.Client, req.Plan.Project.OrgID, t)
+	if err != nil {
+		return err
+	}
+
+	return removeTeam(ctx, req.Client, req.Plan.Project.ID, id)
+}
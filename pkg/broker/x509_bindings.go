@@ -0,0 +1,143 @@
+// Copyright 2020 MongoDB Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mongodb/atlas-osb/pkg/broker/dynamicplans"
+	"github.com/pkg/errors"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+const bindingTypeX509 = "x509"
+
+// Plan setting for the validity of the certificates of X.509 bindings.
+const x509MonthsSetting = "x509MonthsUntilExpiration"
+
+const (
+	defaultX509Months = 3
+	maxX509Months     = 24
+
+	// X.509 users authenticate against the $external database.
+	x509AuthSource = "$external"
+)
+
+type x509BindingParams struct {
+	MonthsUntilExpiration int `json:"monthsUntilExpiration"`
+}
+
+// x509MonthsOf returns the validity of the binding certificate. The bind
+// parameters take precedence over the plan settings.
+func x509MonthsOf(p *dynamicplans.Plan, params *x509BindingParams) (int, error) {
+	months := defaultX509Months
+
+	if _, err := decodeSetting(p.Settings, x509MonthsSetting, &months); err != nil {
+		return 0, err
+	}
+
+	if params != nil && params.MonthsUntilExpiration != 0 {
+		months = params.MonthsUntilExpiration
+	}
+
+	if months < 1 || months > maxX509Months {
+		return 0, fmt.Errorf("certificate validity must be between 1 and %d months, got %d", maxX509Months, months)
+	}
+
+	return months, nil
+}
+
+// toX509User turns a password user into an Atlas-managed X.509 user.
+func toX509User(user *mongodbatlas.DatabaseUser) {
+	user.Password = ""
+	user.X509Type = "MANAGED"
+	user.DatabaseName = x509AuthSource
+}
+
+// x509Options are the connection string options for X.509 authentication.
+func x509Options() url.Values {
+	return url.Values{
+		"authMechanism": {"MONGODB-X509"},
+		"authSource":    {x509AuthSource},
+	}
+}
+
+// addCertificate issues a certificate for the X.509 user of the binding, and
+// deletes the user again if that fails.
+func (b Broker) addCertificate(ctx context.Context, client *mongodbatlas.Client, p *dynamicplans.Plan, user *mongodbatlas.DatabaseUser, months int, d *ConnectionDetails) error {
+	logger := b.funcLogger().With("binding_id", user.Username)
+
+	cert, _, err := client.X509AuthDBUsers.CreateUserCertificate(ctx, p.Project.ID, user.Username, months)
+	if err == nil {
+		err = d.setCertificate(cert.Certificate)
+	}
+
+	if err != nil {
+		// don't leave a user behind which the platform doesn't know about
+		_, delErr := client.DatabaseUsers.Delete(ctx, x509AuthSource, p.Project.ID, user.Username)
+		if delErr != nil {
+			logger.Errorw("Failed to delete Atlas database user", "error", delErr)
+		}
+
+		return errors.Wrap(err, "cannot create X.509 certificate")
+	}
+
+	logger.Infow("Successfully created X.509 certificate", "expires_at", d.CertificateExpiresAt)
+
+	return nil
+}
+
+// setCertificate splits the PEM returned by Atlas into the certificate and
+// its private key.
+func (d *ConnectionDetails) setCertificate(raw string) error {
+	rest := []byte(raw)
+
+	for {
+		block, r := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		rest = r
+
+		if block.Type != "CERTIFICATE" {
+			d.PrivateKey += string(pem.EncodeToMemory(block))
+
+			continue
+		}
+
+		if d.Certificate == "" {
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return errors.Wrap(err, "cannot parse certificate")
+			}
+
+			d.CertificateExpiresAt = c.NotAfter.UTC().Format(time.RFC3339)
+		}
+
+		d.Certificate += string(pem.EncodeToMemory(block))
+	}
+
+	if d.Certificate == "" || d.PrivateKey == "" {
+		return errors.New("no certificate and private key returned by Atlas")
+	}
+
+	return nil
+}
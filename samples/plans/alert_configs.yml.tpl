@@ -0,0 +1,78 @@
+name: alert-configs-plan
+description: "This is sample Plan, it provisions a cluster with a standard set of alerts for replication lag, disk usage and connections."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+# Created on provision and reconciled on update, in addition to the Atlas defaults.
+# https://docs.atlas.mongodb.com/reference/api/alert-configurations-create-config/
+alertConfigs:
+- eventTypeName: "OUTSIDE_METRIC_THRESHOLD"
+  enabled: true
+  metricThreshold:
+    metricName: "OPLOG_SLAVE_LAG_MASTER_TIME"
+    operator: "GREATER_THAN"
+    threshold: 60
+    units: "SECONDS"
+    mode: "AVERAGE"
+  notifications:
+  - typeName: "GROUP"
+    intervalMin: 60
+    delayMin: 0
+    emailEnabled: true
+    roles: ["GROUP_OWNER"]
+- eventTypeName: "OUTSIDE_METRIC_THRESHOLD"
+  enabled: true
+  metricThreshold:
+    metricName: "DISK_PARTITION_SPACE_USED_DATA"
+    operator: "GREATER_THAN"
+    threshold: 80
+    units: "RAW"
+    mode: "AVERAGE"
+  notifications:
+  - typeName: "GROUP"
+    intervalMin: 60
+    delayMin: 0
+    emailEnabled: true
+    roles: ["GROUP_OWNER"]
+- eventTypeName: "OUTSIDE_METRIC_THRESHOLD"
+  enabled: true
+  metricThreshold:
+    metricName: "CONNECTIONS_PERCENT"
+    operator: "GREATER_THAN"
+    threshold: 80
+    units: "RAW"
+    mode: "AVERAGE"
+  notifications:
+  {{- if .slack_token }}
+  - typeName: "SLACK"
+    apiToken: {{ .slack_token }}
+    channelName: {{ default "alerts" .slack_channel }}
+    intervalMin: 60
+    delayMin: 0
+  {{- else }}
+  - typeName: "GROUP"
+    intervalMin: 60
+    delayMin: 0
+    emailEnabled: true
+    roles: ["GROUP_OWNER"]
+  {{- end }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
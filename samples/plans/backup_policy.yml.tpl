@@ -0,0 +1,52 @@
+name: backup-policy-plan
+description: "This is sample Plan, it provisions a cluster with cloud backups, continuous restores and a custom snapshot schedule and retention."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  providerBackupEnabled: true
+  pitEnabled: true
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+# Applied once the cluster is IDLE, replaces the default schedule.
+# https://docs.atlas.mongodb.com/reference/api/cloud-backup/schedule/modify-one-schedule/
+backupPolicy:
+  referenceHourOfDay: 2
+  referenceMinuteOfHour: 30
+  restoreWindowDays: {{ default 7 .restore_window_days }}
+  updateSnapshots: true
+  policies:
+  - policyItems:
+    - frequencyType: "hourly"
+      frequencyInterval: 6
+      retentionUnit: "days"
+      retentionValue: 2
+    - frequencyType: "daily"
+      frequencyInterval: 1
+      retentionUnit: "days"
+      retentionValue: {{ default 7 .daily_retention_days }}
+    - frequencyType: "weekly"
+      frequencyInterval: 6
+      retentionUnit: "weeks"
+      retentionValue: 4
+    - frequencyType: "monthly"
+      frequencyInterval: 40
+      retentionUnit: "months"
+      retentionValue: 12
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
@@ -0,0 +1,41 @@
+name: dev-schedule-plan
+description: "This is sample Plan, it provisions a development cluster which is scaled down in the evening and paused outside of business hours."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+settings:
+  # Applied by the broker's scheduler, see BROKER_SCHEDULER_INTERVAL.
+  pauseSchedule:
+    timezone: {{ default "UTC" .timezone }}
+    days: [Mon, Tue, Wed, Thu, Fri]
+    pause: "20:00"
+    resume: "07:00"
+  scalingSchedule:
+    timezone: {{ default "UTC" .timezone }}
+    steps:
+    - at: "07:00"
+      days: [Mon, Tue, Wed, Thu, Fri]
+      instanceSize: {{ default "M30" .day_instance_size }}
+    - at: "18:00"
+      days: [Mon, Tue, Wed, Thu, Fri]
+      instanceSize: {{ default "M10" .instance_size }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
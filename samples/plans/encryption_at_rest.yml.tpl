@@ -0,0 +1,43 @@
+name: encryption-at-rest-plan
+description: "This is sample Plan, it provisions a cluster encrypted with a customer-managed AWS KMS key, accessed through a cloud provider access role."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+# The role is created on provision. Once the IAM role trusts the Atlas AWS
+# account ARN and external ID shown by the instance, authorize it with the
+# AuthorizeCloudProviderAccess operation.
+# https://docs.atlas.mongodb.com/security/set-up-unified-aws-access/
+cloudProviderAccessRoles:
+- providerName: "AWS"
+  iamAssumedRoleArn: {{ .iam_role_arn }}
+# The key is accessed through the AWS role above unless accessKeyID/secretAccessKey
+# are given, in which case it is configured before the cluster is created.
+# Otherwise the cluster starts without customer key management, which is
+# enabled once the role is authorized.
+# https://docs.atlas.mongodb.com/reference/api/enable-configure-encryptionatrest/
+encryptionAtRest:
+  awsKms:
+    enabled: true
+    customerMasterKeyID: {{ .kms_key_id }}
+    region: {{ default "US_EAST_1" .kms_region }}
+cluster:
+  name: {{ .instance_name }}
+  encryptionAtRestProvider: "AWS"
+  providerSettings:
+    providerName: "AWS"
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
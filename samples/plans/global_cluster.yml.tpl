@@ -0,0 +1,54 @@
+name: global-cluster-plan
+description: "This is sample Plan, it provisions a geo-sharded Global Cluster with custom zone mappings and managed namespaces."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  clusterType: "GEOSHARDED"
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M30" .instance_size }}
+  replicationSpecs:
+  - numShards: 1
+    zoneName: "US"
+    regionsConfig:
+      US_EAST_1:
+        analyticsNodes: 0
+        electableNodes: 3
+        priority: 7
+        readOnlyNodes: 0
+  - numShards: 1
+    zoneName: "EU"
+    regionsConfig:
+      EU_WEST_1:
+        analyticsNodes: 0
+        electableNodes: 3
+        priority: 7
+        readOnlyNodes: 0
+# Global Writes configuration, applied once the cluster is IDLE
+# https://docs.atlas.mongodb.com/reference/api/global-clusters/
+globalClusters:
+- customZoneMappings:
+  - location: "CA"
+    zone: "US"
+  - location: "DE"
+    zone: "EU"
+  managedNamespaces:
+  - db: {{ default "default" .role_db }}
+    collection: "customers"
+    customShardKey: "customerId"
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
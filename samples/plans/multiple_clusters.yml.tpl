@@ -0,0 +1,32 @@
+name: oltp-analytics-plan
+description: "This is sample Plan, it provisions an OLTP cluster and a separate analytics cluster in one project."
+free: true
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+# the first cluster is the default one for bindings
+clusters:
+- name: {{ .instance_name }}-oltp
+  providerBackupEnabled: {{ default "true" .backups }}
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M30" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+- name: {{ .instance_name }}-analytics
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M10" .analytics_instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
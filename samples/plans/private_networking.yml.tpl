@@ -0,0 +1,45 @@
+name: private-networking-plan
+description: "This is sample Plan, it provisions a cluster reachable through VPC peering and an AWS PrivateLink endpoint instead of a public IP access list."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+# Network containers and peering connections are created before the cluster.
+# https://docs.atlas.mongodb.com/reference/api/vpc/
+networkPeering:
+- container:
+    providerName: "AWS"
+    regionName: "US_EAST_1"
+    atlasCidrBlock: {{ default "192.168.248.0/21" .atlas_cidr }}
+  peer:
+    accepterRegionName: "us-east-1"
+    awsAccountId: {{ .aws_account_id }}
+    routeTableCidrBlock: {{ .vpc_cidr }}
+    vpcId: {{ .vpc_id }}
+# Private endpoint services; interface endpoints created in your VPC are added
+# once the service is ready.
+# https://docs.atlas.mongodb.com/reference/api/private-endpoint/
+privateEndpoints:
+- providerName: "AWS"
+  region: "us-east-1"
+  {{- if .interface_endpoint_id }}
+  interfaceEndpoints:
+  - {{ .interface_endpoint_id }}
+  {{- end }}
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    providerName: "AWS"
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: "US_EAST_1"
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- cidrBlock: {{ .vpc_cidr }}
+  comment: "peered VPC"
@@ -0,0 +1,41 @@
+name: project-config-plan
+description: "This is sample Plan, it provisions a cluster in a project with a fixed maintenance window, database auditing and restricted project settings."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+# Sunday, 3 AM (UTC)
+# https://docs.atlas.mongodb.com/reference/api/maintenance-windows/
+maintenanceWindow:
+  dayOfWeek: 1
+  hourOfDay: 3
+# https://docs.atlas.mongodb.com/reference/api/auditing/
+auditing:
+  enabled: true
+  auditAuthorizationSuccess: false
+  auditFilter: |
+    { "atype": { "$in": [ "authenticate", "createUser", "dropUser", "grantRolesToUser" ] } }
+# https://docs.atlas.mongodb.com/reference/api/project-settings/
+projectSettings:
+  isDataExplorerEnabled: false
+  isPerformanceAdvisorEnabled: true
+  isSchemaAdvisorEnabled: true
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
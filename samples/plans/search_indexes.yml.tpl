@@ -0,0 +1,44 @@
+name: search-indexes-plan
+description: "This is sample Plan, it provisions a cluster with Atlas Search indexes."
+free: false
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    providerName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M10" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+# Created once the cluster is IDLE, provisioning completes when they are built.
+# https://docs.atlas.mongodb.com/reference/api/fts-indexes-create-one/
+searchIndexes:
+- name: "default"
+  database: {{ default "default" .role_db }}
+  collectionName: "products"
+  mappings:
+    dynamic: true
+- name: "titles"
+  database: {{ default "default" .role_db }}
+  collectionName: "products"
+  analyzer: "lucene.english"
+  mappings:
+    dynamic: false
+    fields:
+      title:
+        type: "string"
+      description:
+        type: "string"
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
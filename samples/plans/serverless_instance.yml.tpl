@@ -0,0 +1,26 @@
+name: serverless-plan
+description: "This is sample Plan, it provisions a serverless instance instead of a cluster."
+free: true
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    # serverless instances use a separate Atlas API and only support a region
+    providerName: SERVERLESS
+    backingProviderName: {{ default "AWS" .provider }}
+    regionName: {{ default "US_EAST_1" .region }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"
@@ -0,0 +1,27 @@
+name: shared-tier-plan
+description: "This is sample Plan, it provisions a low-cost shared-tier (M0/M2/M5) cluster for development."
+free: true
+apiKey: {{ keyByAlias .credentials "testKey" }}
+project:
+  name: {{ .instance_name }}
+  desc: Created from a template
+cluster:
+  name: {{ .instance_name }}
+  providerSettings:
+    # shared-tier clusters always use the TENANT provider
+    providerName: TENANT
+    backingProviderName: {{ default "AWS" .provider }}
+    instanceSizeName: {{ default "M2" .instance_size }}
+    regionName: {{ default "US_EAST_1" .region }}
+databaseUsers:
+- username: {{ default "test-user" .username }}
+  password: {{ default "test-password" .password }}
+  databaseName: {{ default "admin" .auth_db }}
+  roles:
+  - roleName: {{ default "readWrite" .role }}
+    databaseName: {{ default "default" .role_db }}
+ipAccessLists:
+- ipAddress: "0.0.0.0/1"
+  comment: "everything"
+- ipAddress: "128.0.0.0/1"
+  comment: "everything"